@@ -1,18 +1,18 @@
 package api
 
 import (
-	"fmt"
 	"strings"
 	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 )
 
-const attachementQuery = `query {
-	issue(id: "gid://gitlab/Issue/%s") {
+var issueDesignsQuery = newGraphqlOperation("IssueDesigns", `query IssueDesigns($id: IssueID!, $after: String) {
+	issue(id: $id) {
 		designCollection {
-		  	designs(first:100, after:"%s"){
+		  	designs(first:100, after:$after){
 				pageInfo{
+					hasNextPage
 					endCursor
 				}
 				edges {
@@ -32,8 +32,14 @@ const attachementQuery = `query {
 				}
 			}
 		}
-		discussions(first:100, after:"%s") {
+	}
+}`)
+
+var issueDiscussionsQuery = newGraphqlOperation("IssueDiscussions", `query IssueDiscussions($id: IssueID!, $after: String) {
+	issue(id: $id) {
+		discussions(first:100, after:$after) {
 			pageInfo {
+				hasNextPage
 			  	endCursor
 			}
 			edges {
@@ -52,25 +58,68 @@ const attachementQuery = `query {
 			}
 		}
 	}
-}`
+}`)
+
+type issueDiscussion struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Notes     struct {
+		Nodes []struct {
+			Body   string `json:"body"`
+			Author struct {
+				Username string `json:"username"`
+				ID       string `json:"id"`
+			} `json:"author"`
+		} `json:"nodes"`
+	} `json:"notes"`
+}
+
+func getIssueDiscussionsPage(
+	qc QueryContext,
+	issueRefID string,
+	after NextPage) (pageInfo PageInfo, discussions []issueDiscussion, err error) {
+
+	sdk.LogDebug(qc.Logger, "work issue discussions", "issue", issueRefID, "page", after)
+
+	var GraphQLResponse struct {
+		Issue struct {
+			Discussions struct {
+				PageInfo PageInfo `json:"pageInfo"`
+				Edges    []struct {
+					Node issueDiscussion `json:"node"`
+				} `json:"edges"`
+			} `json:"discussions"`
+		} `json:"issue"`
+	}
+
+	variables := graphqlVariables{"id": "gid://gitlab/Issue/" + issueRefID}.cursor("after", after)
+
+	err = qc.GraphRequester.QueryOperation(issueDiscussionsQuery, variables, &GraphQLResponse)
+	if err != nil {
+		return
+	}
+
+	for _, edge := range GraphQLResponse.Issue.Discussions.Edges {
+		discussions = append(discussions, edge.Node)
+	}
 
-func getIssueAttachmentsPage(
+	return GraphQLResponse.Issue.Discussions.PageInfo, discussions, nil
+}
+
+func getIssueDesignsPage(
 	qc QueryContext,
 	project *GitlabProjectInternal,
 	issueRefID string,
-	designPage NextPage,
-	discussionPage NextPage) (nextDesign NextPage, nextDiscussion NextPage, attachments []*sdk.WorkIssueAttachments, err error) {
+	after NextPage,
+	discussions []issueDiscussion) (pageInfo PageInfo, attachments []*sdk.WorkIssueAttachments, err error) {
 
-	sdk.LogDebug(qc.Logger, "work issue attachments", "project", project.RefID, "issue", issueRefID)
+	sdk.LogDebug(qc.Logger, "work issue attachments", "project", project.RefID, "issue", issueRefID, "page", after)
 
 	var GraphQLResponse struct {
 		Issue struct {
 			DesignCollection struct {
 				Designs struct {
-					PageInfo struct {
-						EndCursor string `json:"endCursor"`
-					} `json:"pageInfo"`
-					Edges []struct {
+					PageInfo PageInfo `json:"pageInfo"`
+					Edges    []struct {
 						Node struct {
 							RefID         string `json:"id"`
 							Filename      string `json:"filename"`
@@ -86,39 +135,17 @@ func getIssueAttachmentsPage(
 					} `json:"edges"`
 				} `json:"designs"`
 			} `json:"designCollection"`
-			Discussions struct {
-				PageInfo struct {
-					EndCursor string `json:"endCursor"`
-				} `json:"pageInfo"`
-				Edges []struct {
-					Node struct {
-						CreatedAt time.Time `json:"createdAt"`
-						Notes     struct {
-							Nodes []struct {
-								Body   string `json:"body"`
-								Author struct {
-									Username string `json:"username"`
-									ID       string `json:"id"`
-								} `json:"author"`
-							} `json:"nodes"`
-						} `json:"notes"`
-					} `json:"node"`
-				} `json:"edges"`
-			} `json:"discussions"`
 		} `json:"issue"`
 	}
 
-	query := fmt.Sprintf(attachementQuery, issueRefID, designPage, discussionPage)
+	variables := graphqlVariables{"id": "gid://gitlab/Issue/" + issueRefID}.cursor("after", after)
 
-	err = qc.GraphRequester.Query(query, nil, &GraphQLResponse)
+	// designs are not available on every instance, the design collection is null in that case
+	err = qc.GraphRequester.QueryOperation(issueDesignsQuery, variables, &GraphQLResponse, WithPartialData())
 	if err != nil {
 		return
 	}
 
-	if len(GraphQLResponse.Issue.Discussions.Edges) == 0 {
-		return
-	}
-
 	for _, edge := range GraphQLResponse.Issue.DesignCollection.Designs.Edges {
 
 		attachment := &sdk.WorkIssueAttachments{
@@ -130,15 +157,19 @@ func getIssueAttachmentsPage(
 			// MimeType: api doesn't response with this data,
 		}
 
-		{
+		if len(edge.Node.Versions.Nodes) > 0 {
 			versionID := edge.Node.Versions.Nodes[0].ID
 			ind := strings.LastIndexAny(versionID, "/")
 			version := versionID[ind+1:]
 
-			for _, edge := range GraphQLResponse.Issue.Discussions.Edges {
-				if strings.Contains(edge.Node.Notes.Nodes[0].Body, version) {
-					sdk.ConvertTimeToDateModel(edge.Node.CreatedAt, &attachment.CreatedDate)
-					userID := edge.Node.Notes.Nodes[0].Author.ID
+			for _, discussion := range discussions {
+				if len(discussion.Notes.Nodes) == 0 {
+					continue
+				}
+				note := discussion.Notes.Nodes[0]
+				if strings.Contains(note.Body, version) {
+					sdk.ConvertTimeToDateModel(discussion.CreatedAt, &attachment.CreatedDate)
+					userID := note.Author.ID
 					ind := strings.LastIndexAny(userID, "/")
 					userRefID := userID[ind+1:]
 					attachment.UserRefID = userRefID
@@ -149,11 +180,7 @@ func getIssueAttachmentsPage(
 		attachments = append(attachments, attachment)
 	}
 
-	nextDesign = NextPage(GraphQLResponse.Issue.DesignCollection.Designs.PageInfo.EndCursor)
-
-	nextDiscussion = NextPage(GraphQLResponse.Issue.Discussions.PageInfo.EndCursor)
-
-	return
+	return GraphQLResponse.Issue.DesignCollection.Designs.PageInfo, attachments, nil
 }
 
 // GetIssueAttachments Get Issue Attachments
@@ -162,18 +189,30 @@ func GetIssueAttachments(
 	project *GitlabProjectInternal,
 	issueRefID string) (allAttachments []sdk.WorkIssueAttachments, err error) {
 
-	var designPage, discussionPage NextPage
-	var attachments []*sdk.WorkIssueAttachments
-	for {
-		designPage, discussionPage, attachments, err = getIssueAttachmentsPage(qc, project, issueRefID, designPage, discussionPage)
+	// the discussions hold the design versions creation date and author
+	var discussions []issueDiscussion
+	err = PaginateCursor(qc.Logger, time.Time{}, func(log sdk.Logger, after NextPage, _ *PageStop) (PageInfo, error) {
+		pageInfo, arr, err := getIssueDiscussionsPage(qc, issueRefID, after)
 		if err != nil {
-			return
+			return pageInfo, err
+		}
+		discussions = append(discussions, arr...)
+		return pageInfo, nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = PaginateCursor(qc.Logger, time.Time{}, func(log sdk.Logger, after NextPage, _ *PageStop) (PageInfo, error) {
+		pageInfo, attachments, err := getIssueDesignsPage(qc, project, issueRefID, after, discussions)
+		if err != nil {
+			return pageInfo, err
 		}
 		for _, a := range attachments {
 			allAttachments = append(allAttachments, *a)
 		}
-		if len(attachments) == 0 {
-			return
-		}
-	}
+		return pageInfo, nil
+	})
+
+	return
 }
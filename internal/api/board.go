@@ -3,7 +3,6 @@ package api
 import (
 	"net/url"
 	"strconv"
-	"strings"
 
 	"github.com/pinpt/agent/v4/sdk"
 )
@@ -37,9 +36,9 @@ func RepoBoardsPage(
 
 	sdk.LogDebug(qc.Logger, "repo boards", "repo", repo.Name, "repo_ref_id", repo.RefID, "params", params)
 
-	objectPath := sdk.JoinURL("projects", repo.RefID, "boards")
+	objectPath := repo.APIPath("boards")
 
-	initialKanbanURL := sdk.JoinURL(qc.BaseURL, "projects", repo.Name, "-", "boards", repo.RefID)
+	initialKanbanURL := sdk.JoinURL(qc.BaseURL, "projects", repo.FullPath, "-", "boards", repo.RefID)
 
 	return boardsCommonPage(qc, repo.ID, objectPath, initialKanbanURL, params, []*GitlabProjectInternal{repo})
 }
@@ -72,7 +71,7 @@ func boardsCommonPage(
 
 	np, err = qc.Get(objectPath, params, &boards)
 	if err != nil {
-		if strings.Contains(err.Error(), "403 Forbidden") {
+		if IsForbidden(err) {
 			sdk.LogWarn(qc.Logger, "user doesn't have permissions to get project boards", "endpoint", objectPath)
 			return np, nil
 		}
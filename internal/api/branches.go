@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/url"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// RepoBranchHead returns the sha of the head commit of the branch
+func RepoBranchHead(qc QueryContext, repo *GitlabProjectInternal, branch string) (string, error) {
+
+	sdk.LogDebug(qc.Logger, "repo branch", "repo", repo.Name, "repo_ref_id", repo.RefID, "branch", branch)
+
+	objectPath := repo.APIPath("repository", "branches", url.QueryEscape(branch))
+
+	var rbranch struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+
+	if _, err := qc.Get(objectPath, nil, &rbranch); err != nil {
+		return "", err
+	}
+
+	return rbranch.Commit.ID, nil
+}
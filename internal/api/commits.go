@@ -0,0 +1,161 @@
+package api
+
+import (
+	"net/url"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// PushHookCommitsLimit is the max number of commits sent in a push event, the rest have to be fetched
+const PushHookCommitsLimit = 20
+
+// blankSha is the before sha of a new branch and the after sha of a deleted one
+const blankSha = "0000000000000000000000000000000000000000"
+
+// WebhookPush push event
+type WebhookPush struct {
+	Before            string     `json:"before"`
+	After             string     `json:"after"`
+	Ref               string     `json:"ref"`
+	UserUsername      string     `json:"user_username"`
+	Commits           []WhCommit `json:"commits"`
+	TotalCommitsCount int        `json:"total_commits_count"`
+}
+
+// Branch returns the branch pushed
+func (p *WebhookPush) Branch() string {
+	return refBranch(p.Ref)
+}
+
+// Created reports if the push created the branch
+func (p *WebhookPush) Created() bool {
+	return p.Before == blankSha
+}
+
+// Deleted reports if the push deleted the branch
+func (p *WebhookPush) Deleted() bool {
+	return p.After == blankSha
+}
+
+// Truncated reports if gitlab left out commits of the push event
+func (p *WebhookPush) Truncated() bool {
+	return p.TotalCommitsCount > len(p.Commits)
+}
+
+func refBranch(ref string) string {
+	const prefix = "refs/heads/"
+	if len(ref) > len(prefix) && ref[:len(prefix)] == prefix {
+		return ref[len(prefix):]
+	}
+	return ref
+}
+
+// CompareCommits returns the commits reachable from to and not from from, from can be a sha or a branch
+func CompareCommits(qc QueryContext, repo *GitlabProjectInternal, from string, to string) (commits []*sdk.SourceCodeCommit, err error) {
+
+	rcommits, err := compare(qc, repo, from, to)
+	if err != nil {
+		return
+	}
+
+	repoID := sdk.NewSourceCodeRepoID(qc.CustomerID, repo.RefID, qc.RefType)
+
+	for _, rcommit := range rcommits {
+
+		author := commitAuthorUserToAuthor(&rcommit)
+		if err = qc.UserManager.EmitGitUser(qc.Logger, author); err != nil {
+			return
+		}
+
+		author = commitCommiterUserToAuthor(&rcommit)
+		if err = qc.UserManager.EmitGitUser(qc.Logger, author); err != nil {
+			return
+		}
+
+		commits = append(commits, rcommit.ToSourceCodeCommit(qc.CustomerID, qc.RefType, repoID))
+	}
+
+	return
+}
+
+func compare(qc QueryContext, repo *GitlabProjectInternal, from string, to string) ([]PrCommit, error) {
+
+	sdk.LogDebug(qc.Logger, "compare commits", "repo", repo.Name, "repo_ref_id", repo.RefID, "from", from, "to", to)
+
+	objectPath := repo.APIPath("repository", "compare")
+
+	params := url.Values{}
+	params.Set("from", from)
+	params.Set("to", to)
+
+	var res struct {
+		Commits []PrCommit `json:"commits"`
+	}
+
+	if _, err := qc.Get(objectPath, params, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Commits, nil
+}
+
+// RepoCommit commit of the repository commits api, with_stats adds the stats
+type RepoCommit struct {
+	PrCommit
+	Stats struct {
+		Additions int64 `json:"additions"`
+		Deletions int64 `json:"deletions"`
+	} `json:"stats"`
+}
+
+// ToSourceCodeCommit convert repo commit to source code commit
+func (rc *RepoCommit) ToSourceCodeCommit(customerID, refType, repoID string) (scc *sdk.SourceCodeCommit) {
+
+	scc = rc.PrCommit.ToSourceCodeCommit(customerID, refType, repoID)
+
+	scc.Additions = rc.Stats.Additions
+	scc.Deletions = rc.Stats.Deletions
+
+	return
+}
+
+// RepoCommitsPage returns a page of the commits of ref, a branch or a revision range like sha..branch
+func RepoCommitsPage(
+	qc QueryContext,
+	repo *GitlabProjectInternal,
+	ref string,
+	params url.Values) (pi NextPage, commits []*sdk.SourceCodeCommit, err error) {
+
+	sdk.LogDebug(qc.Logger, "repo commits", "repo", repo.Name, "repo_ref_id", repo.RefID, "ref", ref, "params", params)
+
+	objectPath := repo.APIPath("repository", "commits")
+
+	params.Set("ref_name", ref)
+	params.Set("with_stats", "true")
+
+	var rcommits []RepoCommit
+
+	pi, err = qc.Get(objectPath, params, &rcommits)
+	if err != nil {
+		return
+	}
+
+	repoID := sdk.NewSourceCodeRepoID(qc.CustomerID, repo.RefID, qc.RefType)
+
+	for _, rcommit := range rcommits {
+
+		author := commitAuthorUserToAuthor(&rcommit.PrCommit)
+		if err = qc.UserManager.EmitGitUser(qc.Logger, author); err != nil {
+			return
+		}
+
+		author = commitCommiterUserToAuthor(&rcommit.PrCommit)
+		if err = qc.UserManager.EmitGitUser(qc.Logger, author); err != nil {
+			return
+		}
+
+		commits = append(commits, rcommit.ToSourceCodeCommit(qc.CustomerID, qc.RefType, repoID))
+	}
+
+	return
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"io"
 	"net/url"
 	"strings"
@@ -25,6 +26,8 @@ type UserManager2 interface {
 
 // QueryContext query context
 type QueryContext struct {
+	// Context is cancelled when the integration is stopped or the export is finished
+	Context context.Context
 	BaseURL string
 	Logger  sdk.Logger
 	Get     func(url string, params url.Values, response interface{}) (NextPage, error)
@@ -90,7 +93,7 @@ func ExtractGraphQLID(id string) string {
 }
 
 func checkPermissionsIssue(logger sdk.Logger, err error, msg string) bool {
-	if strings.Contains(err.Error(), "The resource that you are attempting to access does not exist or you don't have permission to perform this action") {
+	if IsForbidden(err) || IsNotFound(err) {
 		sdk.LogWarn(logger, msg)
 		return true
 	}
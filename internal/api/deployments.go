@@ -0,0 +1,141 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// Environment deployment environment of a project
+type Environment struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Tier production, staging, testing, development or other, older instances don't have it
+	Tier        string `json:"tier"`
+	ExternalURL string `json:"external_url"`
+}
+
+// Deployment deployment of a ref to an environment
+type Deployment struct {
+	ID        int64     `json:"id"`
+	IID       int64     `json:"iid"`
+	Ref       string    `json:"ref"`
+	Sha       string    `json:"sha"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// FinishedAt only set once the deployment is done
+	FinishedAt  *time.Time  `json:"finished_at"`
+	Environment Environment `json:"environment"`
+	Deployable  *struct {
+		ID     int64  `json:"id"`
+		Status string `json:"status"`
+		WebURL string `json:"web_url"`
+	} `json:"deployable"`
+}
+
+// RefID deployment ref id
+func (d *Deployment) RefID() string {
+	return strconv.FormatInt(d.ID, 10)
+}
+
+// ToCICDDeployment convert deployment, tier is the tier of the environment when the deployment doesn't have it
+func (d *Deployment) ToCICDDeployment(customerID, refType, repoName, tier string) *sdk.CICDDeployment {
+	deployment := &sdk.CICDDeployment{}
+	deployment.ID = sdk.NewCICDDeploymentID(customerID, refType, d.RefID())
+	deployment.CustomerID = customerID
+	deployment.RefType = refType
+	deployment.RefID = d.RefID()
+	deployment.RepoName = repoName
+	deployment.CommitSha = d.Sha
+	deployment.Automated = true
+	deployment.Status = deploymentStatus(d.Status)
+	if d.Environment.Tier != "" {
+		tier = d.Environment.Tier
+	}
+	deployment.Environment = deploymentEnvironment(tier, d.Environment.Name)
+	if d.Deployable != nil {
+		deployment.URL = d.Deployable.WebURL
+	}
+	sdk.ConvertTimeToDateModel(d.CreatedAt, &deployment.StartDate)
+	if d.FinishedAt != nil {
+		sdk.ConvertTimeToDateModel(*d.FinishedAt, &deployment.EndDate)
+	} else if deployment.Status != sdk.CICDDeploymentStatusCreated && deployment.Status != sdk.CICDDeploymentStatusRunning {
+		sdk.ConvertTimeToDateModel(d.UpdatedAt, &deployment.EndDate)
+	}
+	return deployment
+}
+
+func deploymentStatus(status string) sdk.CICDDeploymentStatus {
+	switch status {
+	case "running":
+		return sdk.CICDDeploymentStatusRunning
+	case "success":
+		return sdk.CICDDeploymentStatusPass
+	case "failed":
+		return sdk.CICDDeploymentStatusFail
+	case "canceled":
+		return sdk.CICDDeploymentStatusCancel
+	default:
+		// created and blocked
+		return sdk.CICDDeploymentStatusCreated
+	}
+}
+
+// deploymentEnvironment maps the tier of the environment, instances without tiers fall back to the name
+func deploymentEnvironment(tier, name string) sdk.CICDDeploymentEnvironment {
+	if tier == "" {
+		tier = name
+	}
+	switch tier {
+	case "production", "prod":
+		return sdk.CICDDeploymentEnvironmentProduction
+	case "staging", "stage":
+		return sdk.CICDDeploymentEnvironmentStaging
+	case "testing", "test":
+		return sdk.CICDDeploymentEnvironmentTest
+	case "development", "dev":
+		return sdk.CICDDeploymentEnvironmentDevelopment
+	default:
+		return sdk.CICDDeploymentEnvironmentOther
+	}
+}
+
+// EnvironmentsPage returns a page of the environments of the repo
+func EnvironmentsPage(qc QueryContext, repo *GitlabProjectInternal, params url.Values) (pi NextPage, environments []*Environment, err error) {
+
+	sdk.LogDebug(qc.Logger, "repo environments", "repo", repo.Name, "repo_ref_id", repo.RefID, "params", params)
+
+	objectPath := repo.APIPath("environments")
+
+	pi, err = qc.Get(objectPath, params, &environments)
+
+	return
+}
+
+// DeploymentsPage returns a page of the deployments of the repo
+func DeploymentsPage(qc QueryContext, repo *GitlabProjectInternal, params url.Values) (pi NextPage, deployments []*Deployment, err error) {
+
+	sdk.LogDebug(qc.Logger, "repo deployments", "repo", repo.Name, "repo_ref_id", repo.RefID, "params", params)
+
+	objectPath := repo.APIPath("deployments")
+
+	pi, err = qc.Get(objectPath, params, &deployments)
+
+	return
+}
+
+// DeploymentByID returns the deployment
+func DeploymentByID(qc QueryContext, repo *GitlabProjectInternal, id int64) (deployment *Deployment, err error) {
+
+	sdk.LogDebug(qc.Logger, "repo deployment", "repo", repo.Name, "repo_ref_id", repo.RefID, "deployment_id", id)
+
+	objectPath := repo.APIPath("deployments", strconv.FormatInt(id, 10))
+
+	_, err = qc.Get(objectPath, nil, &deployment)
+
+	return
+}
@@ -120,7 +120,7 @@ func EpicsPage(
 
 	np, err = qc.Get(objectPath, params, &repics)
 	if err != nil {
-		if strings.Contains(err.Error(), "403") {
+		if IsForbidden(err) {
 			sdk.LogWarn(qc.Logger, "epics is not available for this namespace, it needs a valid tier", "namespace", namespace.Name)
 			return np, epics, nil
 		}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+var (
+	// ErrNotFound matches any api error with a 404 status
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden matches any api error with a 403 status
+	ErrForbidden = errors.New("forbidden")
+	// ErrUnauthorized matches any api error with a 401 status
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrRateLimited matches any api error with a 429 status
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// Error is an error returned by the gitlab rest or graphql api
+type Error struct {
+	// StatusCode http status returned by gitlab, 0 if the request didn't get a response
+	StatusCode int
+	// Method http method of the request
+	Method string
+	// Endpoint api endpoint of the request
+	Endpoint string
+	// Message error message returned by gitlab
+	Message string
+	// Retryable the request can be retried
+	Retryable bool
+	// Err underlying error
+	Err error
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" && e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("gitlab api error: %s %s: %s", e.Method, e.Endpoint, msg)
+	}
+	return fmt.Sprintf("gitlab api error: %s %s returned %d: %s", e.Method, e.Endpoint, e.StatusCode, msg)
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is allows errors.Is to match the api error against ErrNotFound, ErrForbidden, ErrUnauthorized and ErrRateLimited
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// IsNotFound returns true if the error is a gitlab not found error
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsForbidden returns true if the error is a gitlab forbidden error
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
+
+// IsUnauthorized returns true if the error is a gitlab unauthorized error
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsRateLimited returns true if the error is a gitlab rate limit error
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsRetryable returns true if the error is a gitlab error which can be retried
+func IsRetryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable
+	}
+	return false
+}
+
+func newRequestError(r *internalRequest, resp *sdk.HTTPResponse, err error) *Error {
+	e := &Error{
+		Method:   r.RequestType.String(),
+		Endpoint: r.EndPoint,
+		Err:      err,
+	}
+	if ok, statusCode, body := sdk.IsHTTPError(err); ok {
+		e.StatusCode = statusCode
+		if body != nil {
+			if b, rerr := ioutil.ReadAll(body); rerr == nil {
+				e.Message = errorMessage(b)
+			}
+		}
+	}
+	if resp != nil {
+		if e.StatusCode == 0 {
+			e.StatusCode = resp.StatusCode
+		}
+		if e.Message == "" {
+			e.Message = errorMessage(resp.Body)
+		}
+	}
+	e.Retryable = isStatusRetryable(e.StatusCode)
+	return e
+}
+
+// errorMessage extracts the message from a gitlab error body, which can be {"message": "..."},
+// {"message": {"field": ["..."]}} or {"error": "...", "error_description": "..."}
+func errorMessage(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var res struct {
+		Message          json.RawMessage `json:"message"`
+		Error            string          `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return strings.TrimSpace(string(body))
+	}
+	if len(res.Message) > 0 {
+		var msg string
+		if err := json.Unmarshal(res.Message, &msg); err == nil {
+			return msg
+		}
+		return string(res.Message)
+	}
+	if res.ErrorDescription != "" {
+		return res.Error + ": " + res.ErrorDescription
+	}
+	return res.Error
+}
+
+// graphqlResourceNotAvailable is returned by gitlab graphql when the resource doesn't exist or the user has no access to it
+const graphqlResourceNotAvailable = "The resource that you are attempting to access does not exist or you don't have permission to perform this action"
+
+// GraphqlError is an error of the graphql errors array
+type GraphqlError struct {
+	Message   string `json:"message"`
+	Locations []struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"locations,omitempty"`
+	// Path to the field which failed, it contains field names and list indexes
+	Path []interface{} `json:"path,omitempty"`
+	// Extensions extra information about the error, like the code
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e GraphqlError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	path := make([]string, 0, len(e.Path))
+	for _, p := range e.Path {
+		path = append(path, fmt.Sprint(p))
+	}
+	return fmt.Sprintf("%s (path: %s)", e.Message, strings.Join(path, "."))
+}
+
+// Code returns the error code from the extensions, empty if not available
+func (e GraphqlError) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// GraphqlErrors all the errors returned by a graphql query
+type GraphqlErrors []GraphqlError
+
+func (e GraphqlErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// apiError wraps the graphql errors into an api error, the status is forbidden when gitlab
+// reports the resource is not available
+func (e GraphqlErrors) apiError(statusCode int) *Error {
+	for _, err := range e {
+		if strings.Contains(err.Message, graphqlResourceNotAvailable) {
+			statusCode = http.StatusForbidden
+		}
+	}
+	return &Error{
+		StatusCode: statusCode,
+		Method:     http.MethodPost,
+		Endpoint:   "graphql",
+		Message:    e.Error(),
+		Retryable:  statusCode != http.StatusOK && isStatusRetryable(statusCode),
+		Err:        e,
+	}
+}
+
+// IsGraphqlUndefinedField returns true if the query failed because a field is not available,
+// gitlab doesn't expose the fields of features which are not included in the instance tier
+func IsGraphqlUndefinedField(err error) bool {
+	var gerrs GraphqlErrors
+	if errors.As(err, &gerrs) {
+		for _, e := range gerrs {
+			if e.Code() == "undefinedField" {
+				return true
+			}
+		}
+	}
+	return false
+}
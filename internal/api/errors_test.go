@@ -0,0 +1,48 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestError(t *testing.T) {
+
+	assert := assert.New(t)
+
+	req := &internalRequest{EndPoint: "projects/1/boards", RequestType: Get}
+
+	err := newRequestError(req, nil, &sdk.HTTPError{
+		StatusCode: http.StatusForbidden,
+		Body:       bytes.NewBufferString(`{"message":"403 Forbidden"}`),
+	})
+
+	assert.Equal(http.StatusForbidden, err.StatusCode)
+	assert.Equal("GET", err.Method)
+	assert.Equal("projects/1/boards", err.Endpoint)
+	assert.Equal("403 Forbidden", err.Message)
+	assert.False(err.Retryable)
+
+	wrapped := fmt.Errorf("can't retry request, err: %w", err)
+	assert.True(IsForbidden(wrapped))
+	assert.False(IsNotFound(wrapped))
+
+	var apiErr *Error
+	assert.True(errors.As(wrapped, &apiErr))
+	assert.Equal(http.StatusForbidden, apiErr.StatusCode)
+}
+
+func TestErrorMessage(t *testing.T) {
+
+	assert := assert.New(t)
+
+	assert.Equal("404 Project Not Found", errorMessage([]byte(`{"message":"404 Project Not Found"}`)))
+	assert.Equal(`{"url":["is blocked"]}`, errorMessage([]byte(`{"message":{"url":["is blocked"]}}`)))
+	assert.Equal("invalid_token: Token was revoked", errorMessage([]byte(`{"error":"invalid_token","error_description":"Token was revoked"}`)))
+	assert.Equal("Bad Gateway", errorMessage([]byte("Bad Gateway")))
+}
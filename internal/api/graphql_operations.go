@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// GraphqlOperation is a named graphql query or mutation, the values are always
+// sent as declared variables and never interpolated into the query text
+type GraphqlOperation struct {
+	Name  string
+	Query string
+}
+
+var graphqlOperations = make(map[string]GraphqlOperation)
+
+var graphqlOperationName = regexp.MustCompile(`^\s*(query|mutation)\s+(\w+)`)
+
+// newGraphqlOperation registers a named operation, the name must match the one declared in the query
+func newGraphqlOperation(name string, query string) GraphqlOperation {
+	match := graphqlOperationName.FindStringSubmatch(query)
+	if match == nil || match[2] != name {
+		panic(fmt.Sprintf("graphql operation %s must declare its name in the query", name))
+	}
+	if _, ok := graphqlOperations[name]; ok {
+		panic(fmt.Sprintf("graphql operation %s already registered", name))
+	}
+	op := GraphqlOperation{Name: name, Query: query}
+	graphqlOperations[name] = op
+	return op
+}
+
+// graphqlVariables builds the variables map for an operation skipping the empty cursors
+// so the first page is requested with a null cursor
+type graphqlVariables map[string]interface{}
+
+func (v graphqlVariables) cursor(name string, page NextPage) graphqlVariables {
+	if page != "" {
+		v[name] = string(page)
+	}
+	return v
+}
+
+// QueryOperation runs a named graphql operation with its variables
+func (e *GraphqlRequester) QueryOperation(op GraphqlOperation, variables map[string]interface{}, out interface{}, options ...GraphqlQueryOption) error {
+	sdk.LogDebug(e.logger, "graphql operation", "name", op.Name, "variables", variables)
+	return e.Query(op.Query, variables, out, options...)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	graphqlDeclaredVariable = regexp.MustCompile(`\$(\w+)\s*:`)
+	graphqlUsedVariable     = regexp.MustCompile(`:\s*\$(\w+)`)
+)
+
+func graphqlVariableNames(re *regexp.Regexp, query string) []string {
+	names := make(map[string]bool)
+	for _, match := range re.FindAllStringSubmatch(query, -1) {
+		names[match[1]] = true
+	}
+	return sdk.Keys(names)
+}
+
+func TestGraphqlOperationsVariables(t *testing.T) {
+
+	assert := assert.New(t)
+
+	assert.NotEmpty(graphqlOperations)
+
+	for name, op := range graphqlOperations {
+		header := op.Query[:strings.Index(op.Query, "{")]
+		body := op.Query[strings.Index(op.Query, "{"):]
+
+		declared := graphqlVariableNames(graphqlDeclaredVariable, header)
+		used := graphqlVariableNames(graphqlUsedVariable, body)
+		sort.Strings(declared)
+		sort.Strings(used)
+
+		assert.Equal(declared, used, "operation %s", name)
+		assert.NotContains(op.Query, "%s", "operation %s", name)
+	}
+}
+
+func TestGraphqlVariablesCursor(t *testing.T) {
+
+	assert := assert.New(t)
+
+	variables := graphqlVariables{"fullPath": "group/project"}.cursor("after", "")
+	assert.Equal(graphqlVariables{"fullPath": "group/project"}, variables)
+
+	variables = graphqlVariables{"fullPath": "group/project"}.cursor("after", "abc")
+	assert.Equal("abc", variables["after"])
+}
+
+func TestMakeIterationUpdate(t *testing.T) {
+
+	assert := assert.New(t)
+
+	name := `sprint "1"`
+	goal := `goal"}) { errors } }`
+
+	var event sdk.AgileSprintUpdateMutation
+	event.Set.Name = &name
+	event.Set.Goal = &goal
+
+	input, hasMutation, err := makeIterationUpdate(&event)
+	assert.NoError(err)
+	assert.True(hasMutation)
+	assert.Equal(name, input["title"])
+	assert.Equal(goal, input["description"])
+}
@@ -1,46 +1,160 @@
 package api
 
 import (
-	"strings"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
 
 	"github.com/pinpt/agent/v4/sdk"
 )
 
 type GraphqlRequester struct {
+	ctx         context.Context
 	concurrency chan bool
-	graphClient sdk.GraphQLClient
+	client      sdk.HTTPClient
+	rateLimiter *RateLimiter
+	retryPolicy RetryPolicy
 	logger      sdk.Logger
 }
 
-// NewGraphqlRequester new graphql requester
-func NewGraphqlRequester(client sdk.GraphQLClient, concurrency int, logger sdk.Logger) GraphqlRequester {
+// NewGraphqlRequester new graphql requester, client must point to the graphql endpoint,
+// outstanding queries are cancelled once ctx is done
+func NewGraphqlRequester(ctx context.Context, client sdk.HTTPClient, rateLimiter *RateLimiter, retryPolicy RetryPolicy, concurrency int, logger sdk.Logger) GraphqlRequester {
 	return GraphqlRequester{
-		graphClient: client,
+		ctx:         ctx,
+		client:      client,
+		rateLimiter: rateLimiter,
+		retryPolicy: retryPolicy,
 		concurrency: make(chan bool, concurrency),
 		logger:      logger,
 	}
 }
 
+type graphqlQueryOptions struct {
+	partialData bool
+}
+
+// GraphqlQueryOption graphql query option
+type GraphqlQueryOption func(opts *graphqlQueryOptions)
+
+// WithPartialData accepts the data returned along with graphql errors, the errors are logged
+// and the query doesn't fail as long as gitlab returns some data
+func WithPartialData() GraphqlQueryOption {
+	return func(opts *graphqlQueryOptions) {
+		opts.partialData = true
+	}
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphqlErrors   `json:"errors"`
+}
+
+func (r *graphqlResponse) hasData() bool {
+	data := bytes.TrimSpace(r.Data)
+	return len(data) > 0 && !bytes.Equal(data, []byte("null"))
+}
+
 // Query query
-func (e *GraphqlRequester) Query(query string, variables map[string]interface{}, out interface{}) error {
+func (e *GraphqlRequester) Query(query string, variables map[string]interface{}, out interface{}, options ...GraphqlQueryOption) error {
 	e.concurrency <- true
 	defer func() {
 		<-e.concurrency
 	}()
 
-	retryCount := 0
+	var opts graphqlQueryOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
+	var retries, throttledRetries int
 
 	for {
-		err := e.graphClient.Query(query, variables, out)
-		if err != nil && strings.Contains(err.Error(), "status code: 429 Too Many Requests") {
-			rateLimit(e.logger, retryCount)
-			retryCount++
+		res, err := e.request(query, variables)
+		if err == nil {
+			return e.decode(res, out, opts)
+		}
+		if !IsRetryable(err) {
+			return err
+		}
+		if IsRateLimited(err) {
+			if throttledRetries >= e.retryPolicy.MaxThrottledRetries {
+				return fmt.Errorf(`can't retry query, too many throttled retries, err: %w`, err)
+			}
+			// the rate limiter waits for the reset before the next attempt
+			sdk.LogWarn(e.logger, "graphql request failed due to throttling, will wait for the rate limit reset and retry", "retry", throttledRetries)
+			throttledRetries++
 			continue
-		} else {
+		}
+		if retries >= e.retryPolicy.MaxRetries {
+			return fmt.Errorf(`can't retry query, too many retries, err: %w`, err)
+		}
+		sdk.LogWarn(e.logger, "graphql request failed, retrying", "retry", retries, "err", err)
+		if err := sleepContext(e.ctx, e.retryPolicy.Backoff(retries)); err != nil {
 			return err
 		}
+		retries++
 	}
+}
 
-	return e.graphClient.Query(query, variables, out)
+func (e *GraphqlRequester) request(query string, variables map[string]interface{}) (*graphqlResponse, error) {
 
+	if err := e.rateLimiter.Wait(e.ctx); err != nil {
+		return nil, err
+	}
+
+	payload := struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables,omitempty"`
+	}{query, variables}
+
+	var throttled bool
+	var res graphqlResponse
+
+	resp, err := e.client.Post(sdk.StringifyReader(payload), &res, withHTTPContext(e.ctx), e.rateLimiter.responseOption(&throttled))
+	if err == nil {
+		return &res, nil
+	}
+	if err := e.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r := &internalRequest{EndPoint: "graphql", RequestType: Post}
+	if throttled {
+		return nil, &Error{
+			StatusCode: http.StatusTooManyRequests,
+			Method:     r.RequestType.String(),
+			Endpoint:   r.EndPoint,
+			Message:    "too many requests",
+			Retryable:  true,
+		}
+	}
+
+	// gitlab can answer with the graphql error envelope along with an error status
+	if ok, statusCode, body := sdk.IsHTTPError(err); ok && body != nil {
+		if b, rerr := ioutil.ReadAll(body); rerr == nil {
+			var envelope graphqlResponse
+			if json.Unmarshal(b, &envelope) == nil && len(envelope.Errors) > 0 {
+				return nil, envelope.Errors.apiError(statusCode)
+			}
+			err = &sdk.HTTPError{StatusCode: statusCode, Body: bytes.NewReader(b)}
+		}
+	}
+	return nil, newRequestError(r, resp, err)
+}
+
+func (e *GraphqlRequester) decode(res *graphqlResponse, out interface{}, opts graphqlQueryOptions) error {
+	if len(res.Errors) > 0 {
+		if !opts.partialData || !res.hasData() {
+			return res.Errors.apiError(http.StatusOK)
+		}
+		sdk.LogWarn(e.logger, "graphql query returned partial data", "errors", res.Errors.Error())
+	}
+	if out == nil || !res.hasData() {
+		return nil
+	}
+	return json.Unmarshal(res.Data, out)
 }
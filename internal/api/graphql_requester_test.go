@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGraphqlClient struct {
+	sdk.HTTPClient
+	statusCode int
+	body       string
+	payloads   []string
+}
+
+func (c *fakeGraphqlClient) Post(data io.Reader, out interface{}, options ...sdk.WithHTTPOption) (*sdk.HTTPResponse, error) {
+	var buf bytes.Buffer
+	io.Copy(&buf, data)
+	c.payloads = append(c.payloads, buf.String())
+	res := &sdk.HTTPResponse{StatusCode: c.statusCode, Headers: http.Header{}, Body: []byte(c.body)}
+	if c.statusCode > 299 {
+		return res, &sdk.HTTPError{StatusCode: c.statusCode, Body: bytes.NewBufferString(c.body)}
+	}
+	return res, json.Unmarshal(res.Body, out)
+}
+
+func newTestGraphqlRequester(client sdk.HTTPClient) GraphqlRequester {
+	logger := sdk.NewNoOpTestLogger()
+	policy := DefaultRetryPolicy()
+	policy.MaxRetries = 0
+	return NewGraphqlRequester(context.Background(), client, NewRateLimiter(logger), policy, 1, logger)
+}
+
+func TestGraphqlRequesterData(t *testing.T) {
+
+	assert := assert.New(t)
+
+	client := &fakeGraphqlClient{statusCode: http.StatusOK, body: `{"data":{"group":{"id":"1"}}}`}
+	requester := newTestGraphqlRequester(client)
+
+	var out struct {
+		Group struct {
+			ID string `json:"id"`
+		} `json:"group"`
+	}
+	err := requester.QueryOperation(iterationsQuery, graphqlVariables{"fullPath": `my "group"`}, &out)
+	assert.NoError(err)
+	assert.Equal("1", out.Group.ID)
+
+	var payload struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	assert.NoError(json.Unmarshal([]byte(client.payloads[0]), &payload))
+	assert.Equal(iterationsQuery.Query, payload.Query)
+	assert.Equal(`my "group"`, payload.Variables["fullPath"])
+}
+
+func TestGraphqlRequesterErrors(t *testing.T) {
+
+	assert := assert.New(t)
+
+	body := `{"data":{"group":null},"errors":[{"message":"` + graphqlResourceNotAvailable + `","path":["group","iterations",0],"extensions":{"code":"forbidden"}}]}`
+	requester := newTestGraphqlRequester(&fakeGraphqlClient{statusCode: http.StatusOK, body: body})
+
+	var out map[string]interface{}
+	err := requester.Query("query { group { id } }", nil, &out)
+	assert.Error(err)
+	assert.True(IsForbidden(err))
+	assert.False(IsRetryable(err))
+
+	var gerrs GraphqlErrors
+	assert.True(errors.As(err, &gerrs))
+	assert.Len(gerrs, 1)
+	assert.Equal([]interface{}{"group", "iterations", float64(0)}, gerrs[0].Path)
+	assert.Equal("forbidden", gerrs[0].Code())
+}
+
+func TestGraphqlRequesterPartialData(t *testing.T) {
+
+	assert := assert.New(t)
+
+	body := `{"data":{"issue":{"id":"1","designCollection":null}},"errors":[{"message":"designs not available","path":["issue","designCollection"]}]}`
+	requester := newTestGraphqlRequester(&fakeGraphqlClient{statusCode: http.StatusOK, body: body})
+
+	var out struct {
+		Issue struct {
+			ID string `json:"id"`
+		} `json:"issue"`
+	}
+	err := requester.Query("query { issue { id } }", nil, &out)
+	assert.Error(err)
+	assert.Empty(out.Issue.ID)
+
+	err = requester.Query("query { issue { id } }", nil, &out, WithPartialData())
+	assert.NoError(err)
+	assert.Equal("1", out.Issue.ID)
+}
+
+func TestGraphqlRequesterUndefinedField(t *testing.T) {
+
+	assert := assert.New(t)
+
+	body := `{"errors":[{"message":"Field 'iterations' doesn't exist on type 'Group'","extensions":{"code":"undefinedField","typeName":"Group","fieldName":"iterations"}}]}`
+	requester := newTestGraphqlRequester(&fakeGraphqlClient{statusCode: http.StatusOK, body: body})
+
+	err := requester.Query("query { group { iterations { id } } }", nil, nil, WithPartialData())
+	assert.True(IsGraphqlUndefinedField(err))
+}
+
+func TestGraphqlRequesterHTTPError(t *testing.T) {
+
+	assert := assert.New(t)
+
+	requester := newTestGraphqlRequester(&fakeGraphqlClient{statusCode: http.StatusUnauthorized, body: `{"error":"invalid_token","error_description":"Token was revoked"}`})
+
+	err := requester.Query("query { currentUser { id } }", nil, nil)
+	assert.True(IsUnauthorized(err))
+	assert.Contains(err.Error(), "Token was revoked")
+}
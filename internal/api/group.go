@@ -26,7 +26,7 @@ type Namespace struct {
 
 // AllNamespaces all namespaces
 func AllNamespaces(qc QueryContext) (allnamespaces []*Namespace, err error) {
-	err = Paginate(qc.Logger, "", time.Time{}, func(log sdk.Logger, paginationParams url.Values, t time.Time) (np NextPage, _ error) {
+	err = Paginate(qc.Logger, "", time.Time{}, func(log sdk.Logger, paginationParams url.Values, _ *PageStop) (np NextPage, _ error) {
 		paginationParams.Set("top_level_only", "true")
 
 		pi, namespaces, err := namespaces(qc, paginationParams)
@@ -129,6 +129,63 @@ func GroupUser(qc QueryContext, namespace *Namespace, userId string) (u *GitlabU
 	return
 }
 
+// accessLevelNames are the names of the access levels, the system hooks send the names
+var accessLevelNames = map[int64]string{
+	10: "Guest",
+	20: "Reporter",
+	30: "Developer",
+	40: "Maintainer",
+	50: "Owner",
+}
+
+// AccessLevelName returns the name of the access level like the system hooks send it
+func AccessLevelName(level int64) string {
+	if name, ok := accessLevelNames[level]; ok {
+		return name
+	}
+	return strconv.FormatInt(level, 10)
+}
+
+// GroupMembersPage returns a page of the direct members of the group
+func GroupMembersPage(qc QueryContext, namespace *Namespace, params url.Values) (pi NextPage, members []*GitlabUser, err error) {
+
+	sdk.LogDebug(qc.Logger, "group members", "namespace_name", namespace.Name, "namespace_id", namespace.ID)
+
+	objectPath := sdk.JoinURL("groups", namespace.ID, "members")
+
+	pi, err = qc.Get(objectPath, params, &members)
+	if err != nil {
+		return
+	}
+
+	for _, member := range members {
+		member.StrID = strconv.FormatInt(member.RefID, 10)
+	}
+
+	return
+}
+
+// GroupFullPath returns the full path of the group, the path of its parents included
+func GroupFullPath(qc QueryContext, groupID int64) (string, error) {
+
+	sdk.LogDebug(qc.Logger, "group full path", "group_id", groupID)
+
+	params := url.Values{}
+	params.Set("with_projects", "false")
+
+	objectPath := sdk.JoinURL("groups", strconv.FormatInt(groupID, 10))
+
+	var rgroup struct {
+		FullPath string `json:"full_path"`
+	}
+
+	if _, err := qc.Get(objectPath, params, &rgroup); err != nil {
+		return "", err
+	}
+
+	return rgroup.FullPath, nil
+}
+
 // GroupProjects get group projects
 func GroupProjectsIDs(qc QueryContext, group *Namespace) ([]string, error) {
 
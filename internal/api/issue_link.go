@@ -25,7 +25,7 @@ func getIssueLinksPage(
 
 	sdk.LogDebug(qc.Logger, "work issue links", "project", project.RefID, "params", params)
 
-	objectPath := sdk.JoinURL("projects", url.QueryEscape(project.RefID), "issues", issueIID, "links")
+	objectPath := project.APIPath("issues", issueIID, "links")
 
 	var links []issueLink
 
@@ -71,7 +71,7 @@ func GetIssueLinks(
 	project *GitlabProjectInternal,
 	issueIID string) (linkedIssues []sdk.WorkIssueLinkedIssues, err error) {
 
-	err = Paginate(qc.Logger, "", time.Time{}, func(log sdk.Logger, params url.Values, stopOnUpdatedAt time.Time) (NextPage, error) {
+	err = Paginate(qc.Logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *PageStop) (NextPage, error) {
 		np, links, err := getIssueLinksPage(qc, project, issueIID, params)
 		if err != nil {
 			return np, err
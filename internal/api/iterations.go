@@ -26,10 +26,11 @@ type GraphQLIteration struct {
 	WebPath     string    `json:"webPath"`
 }
 
-const iterationsQuery = `query {
-	group(fullPath:"%s"){
-	  iterations(first:100,after:"%s"){
+var iterationsQuery = newGraphqlOperation("GroupIterations", `query GroupIterations($fullPath: ID!, $after: String) {
+	group(fullPath:$fullPath){
+	  iterations(first:100,after:$after){
 		pageInfo{
+		  hasNextPage
 		  endCursor
 		}
 		edges{
@@ -48,63 +49,47 @@ const iterationsQuery = `query {
 		}
 	  }
 	}
-  }`
-
-const createIteration = `mutation {
-	createIteration(input:{
-	  clientMutationId:"%s",
-	  title:"%s",
-	  description:"%s",
-	  groupPath:"%s",
-	  startDate:"%s",
-	  dueDate:"%s",
-	}) {
+  }`)
+
+var createIteration = newGraphqlOperation("CreateIteration", `mutation CreateIteration($input: CreateIterationInput!) {
+	createIteration(input:$input) {
 	  errors
 	  iteration {
 		id
 		title
 	  }
 	}
-  }`
+  }`)
 
 func getIterationsPage(
 	qc QueryContext,
 	namespace *Namespace,
-	iterationPage NextPage) (nextPage NextPage, sprints []*sdk.AgileSprint, err error) {
+	after NextPage) (pageInfo PageInfo, sprints []*sdk.AgileSprint, err error) {
 
-	sdk.LogDebug(qc.Logger, "group iterations", "namespace", namespace.Name, "page", iterationPage)
+	sdk.LogDebug(qc.Logger, "group iterations", "namespace", namespace.Name, "page", after)
 
 	var Data struct {
 		Group struct {
 			Iterations struct {
-				PageInfo struct {
-					EndCursor string `json:"endCursor"`
-				} `json:"pageInfo"`
-				Edges []struct {
+				PageInfo PageInfo `json:"pageInfo"`
+				Edges    []struct {
 					Node GraphQLIteration `json:"node"`
 				} `json:"edges"`
 			} `json:"iterations"`
 		} `json:"group"`
-		Error            string `json:"error"`
-		ErrorDescription string `json:"error_description"`
-		Scope            string `json:"scope"`
 	}
 
-	query := fmt.Sprintf(iterationsQuery, namespace.Name, iterationPage)
+	variables := graphqlVariables{"fullPath": namespace.FullPath}.cursor("after", after)
 
-	err = qc.GraphRequester.Query(query, nil, &Data)
+	err = qc.GraphRequester.QueryOperation(iterationsQuery, variables, &Data)
 	if err != nil {
 		if checkPermissionsIssue(qc.Logger, err, fmt.Sprintf("no permissions to get iterations on this group %s", namespace.Name)) {
-			return nextPage, sprints, nil
+			return pageInfo, sprints, nil
+		}
+		if IsGraphqlUndefinedField(err) {
+			sdk.LogWarn(qc.Logger, "iterations are not available for this namespace, it needs a valid tier", "namespace", namespace.Name, "err", err)
+			return pageInfo, sprints, nil
 		}
-		return
-	}
-
-	if Data.Error != "" {
-		return nextPage, sprints, fmt.Errorf("error getting iterations, err %s", sdk.Stringify(Data))
-	}
-
-	if len(Data.Group.Iterations.Edges) == 0 {
 		return
 	}
 
@@ -160,9 +145,7 @@ func getIterationsPage(
 
 	}
 
-	nextPage = NextPage(Data.Group.Iterations.PageInfo.EndCursor)
-
-	return
+	return Data.Group.Iterations.PageInfo, sprints, nil
 }
 
 // GetIterations get iterations
@@ -170,20 +153,15 @@ func GetIterations(
 	qc QueryContext,
 	namespace *Namespace) (allSprints []*sdk.AgileSprint, err error) {
 
-	var nextPage NextPage
-	var sprints []*sdk.AgileSprint
-	for {
-		nextPage, sprints, err = getIterationsPage(qc, namespace, nextPage)
+	err = PaginateCursor(qc.Logger, time.Time{}, func(log sdk.Logger, after NextPage, _ *PageStop) (PageInfo, error) {
+		pageInfo, sprints, err := getIterationsPage(qc, namespace, after)
 		if err != nil {
-			return
-		}
-		for _, a := range sprints {
-			allSprints = append(allSprints, a)
+			return pageInfo, err
 		}
-		if len(sprints) == 0 {
-			return
-		}
-	}
+		allSprints = append(allSprints, sprints...)
+		return pageInfo, nil
+	})
+	return
 }
 
 type createIterationResponse struct {
@@ -192,14 +170,6 @@ type createIterationResponse struct {
 		Errors     []string         `json:"errors"`
 		Iteration  GraphQLIteration `json:"iteration"`
 	} `json:"createIteration"`
-	Errors []struct {
-		Message   string `json:"message"`
-		Locations []struct {
-			Line   int `json:"line"`
-			Column int `json:"column"`
-		} `json:"locations"`
-		Path []string `json:"path"`
-	} `json:"errors"`
 }
 
 // CreateSprint create sprint
@@ -210,9 +180,18 @@ func CreateSprint(qc QueryContext, startDate, endDate time.Time, groupName, clie
 	sDate := startDate.Format(GitLabDateFormat)
 	eDate := endDate.Format(GitLabDateFormat)
 
-	query := fmt.Sprintf(createIteration, clientMutationID, sprintName, sprintGoal, groupName, sDate, eDate)
+	variables := graphqlVariables{
+		"input": map[string]interface{}{
+			"clientMutationId": clientMutationID,
+			"title":            sprintName,
+			"description":      sprintGoal,
+			"groupPath":        groupName,
+			"startDate":        sDate,
+			"dueDate":          eDate,
+		},
+	}
 
-	if err := qc.GraphRequester.Query(query, nil, &iteration); err != nil {
+	if err := qc.GraphRequester.QueryOperation(createIteration, variables, &iteration); err != nil {
 		if checkPermissionsIssue(qc.Logger, err, fmt.Sprintf("no permissions to create sprint on this group %s, sprint %s", groupName, sprintName)) {
 			return nil
 		}
@@ -223,10 +202,6 @@ func CreateSprint(qc QueryContext, startDate, endDate time.Time, groupName, clie
 		return fmt.Errorf("error creating sprint: namespace %s, error %q", groupName, iteration.CreateIteration.Errors)
 	}
 
-	if len(iteration.Errors) > 0 {
-		return fmt.Errorf("error creating sprint: namespace %s, errors %s", groupName, sdk.Stringify(iteration.Errors))
-	}
-
 	return nil
 }
 
@@ -269,17 +244,15 @@ func CreateSprintFromMutation(qc QueryContext, mutationID string, mutation *sdk.
 
 }
 
-const updateIterationQuery = `mutation {
-	updateIteration(input:{
-	  id:"%s",%s
-	}) {
+var updateIterationQuery = newGraphqlOperation("UpdateIteration", `mutation UpdateIteration($input: UpdateIterationInput!) {
+	updateIteration(input:$input) {
 	  errors
 	  iteration {
 		id
 		title
 	  }
 	}
-  }`
+  }`)
 
 type updateIterationResponse struct {
 	CreateIteration struct {
@@ -291,7 +264,7 @@ type updateIterationResponse struct {
 func UpdateSprint(qc QueryContext, mutation sdk.Mutation, event *sdk.AgileSprintUpdateMutation) (*sdk.MutationResponse, error) {
 
 	iterationRefID := mutation.ID()
-	subquery, hasMutation, err := makeIterationUpdate(event)
+	input, hasMutation, err := makeIterationUpdate(event)
 	if err != nil {
 		return nil, err
 	}
@@ -299,9 +272,9 @@ func UpdateSprint(qc QueryContext, mutation sdk.Mutation, event *sdk.AgileSprint
 	var iteration updateIterationResponse
 	if hasMutation {
 
-		query := fmt.Sprintf(updateIterationQuery, iterationRefID, subquery)
+		input["id"] = iterationRefID
 
-		err := qc.GraphRequester.Query(query, nil, &iteration)
+		err := qc.GraphRequester.QueryOperation(updateIterationQuery, graphqlVariables{"input": input}, &iteration)
 		if err != nil {
 			return nil, err
 		}
@@ -341,31 +314,31 @@ func UpdateSprint(qc QueryContext, mutation sdk.Mutation, event *sdk.AgileSprint
 	}, nil
 }
 
-func makeIterationUpdate(event *sdk.AgileSprintUpdateMutation) (string, bool, error) {
+func makeIterationUpdate(event *sdk.AgileSprintUpdateMutation) (map[string]interface{}, bool, error) {
 
 	var hasMutation bool
-	var subquery string
+	input := make(map[string]interface{})
 	if event.Set.Name != nil {
-		subquery += fmt.Sprintf("title:\"%s\",", *event.Set.Name)
+		input["title"] = *event.Set.Name
 		hasMutation = true
 	}
 	if event.Set.Goal != nil {
-		subquery += fmt.Sprintf("description:\"%s\",", *event.Set.Goal)
+		input["description"] = *event.Set.Goal
 		hasMutation = true
 	}
 	if event.Set.StartDate != nil {
 		startDate := sdk.DateFromEpoch(event.Set.StartDate.Epoch)
-		subquery += fmt.Sprintf("startDate:\"%s\",", startDate.Format("2006-01-02"))
+		input["startDate"] = startDate.Format(GitLabDateFormat)
 		hasMutation = true
 	}
 	if event.Set.EndDate != nil {
 		endDate := sdk.DateFromEpoch(event.Set.EndDate.Epoch)
-		subquery += fmt.Sprintf("dueDate:\"%s\",", endDate.Format("2006-01-02"))
+		input["dueDate"] = endDate.Format(GitLabDateFormat)
 		hasMutation = true
 	}
 	// TODO: change FIX_THIS to the value the UI sends
-	subquery += fmt.Sprintf("groupPath:\"%s\"", "FIX_THIS")
-	return subquery, hasMutation, nil
+	input["groupPath"] = "FIX_THIS"
+	return input, hasMutation, nil
 }
 
 // CreateHelperSprintToUnsetIssues create helper sprint to unset issues
@@ -375,7 +348,8 @@ func CreateHelperSprintToUnsetIssues(qc QueryContext, namespace *Namespace) erro
 		return nil
 	}
 
-	groupName := namespace.Name
+	// the graphql api identifies the groups by full path
+	groupName := namespace.FullPath
 
 	startDate := time.Now().Add((time.Hour * 24 * 2) * 365 * 10)
 	endDate := startDate.Add(time.Hour * 24)
@@ -384,7 +358,7 @@ func CreateHelperSprintToUnsetIssues(qc QueryContext, namespace *Namespace) erro
 	mutationIdentifier := "export_" + groupName + "_" + time.Now().Format("2006-01-02T15_04_05Z07_00")
 
 	var iterationID string
-	ok, err := qc.State.Get(iterationGroupKey(namespace.Name), &iterationID)
+	ok, err := qc.State.Get(iterationGroupKey(groupName), &iterationID)
 	if err != nil {
 		return err
 	}
@@ -392,11 +366,11 @@ func CreateHelperSprintToUnsetIssues(qc QueryContext, namespace *Namespace) erro
 		if err := CreateSprint(qc, startDate, endDate, groupName, mutationIdentifier, helperIterationTitle, "iteration helper to unset issues", &iteration); err != nil {
 			if strings.Contains(err.Error(), "Title already being used for another group or project iteration") {
 				// get the iteration id
-				iteration, err := IterationByTitle(qc, namespace.Name, helperIterationTitle)
+				iteration, err := IterationByTitle(qc, namespace.ID, helperIterationTitle)
 				if err != nil {
 					return err
 				}
-				err = qc.State.Set(iterationGroupKey(namespace.Name), strconv.FormatInt(iteration.RefID, 10))
+				err = qc.State.Set(iterationGroupKey(groupName), strconv.FormatInt(iteration.RefID, 10))
 				if err != nil {
 					return err
 				}
@@ -422,7 +396,7 @@ type restIteration struct {
 	RefID int64 `json:"id"`
 }
 
-// IterationByTitle get iteration by title
+// IterationByTitle get iteration by title, group is the group id
 func IterationByTitle(qc QueryContext, group, title string) (*restIteration, error) {
 
 	sdk.LogDebug(qc.Logger, "iteartion by title", "title", title)
@@ -430,7 +404,7 @@ func IterationByTitle(qc QueryContext, group, title string) (*restIteration, err
 	params := url.Values{}
 	params.Set("search", title)
 
-	objectPath := sdk.JoinURL("groups", url.QueryEscape(group), "iterations")
+	objectPath := sdk.JoinURL("groups", group, "iterations")
 
 	var ri []*restIteration
 
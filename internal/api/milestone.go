@@ -28,34 +28,34 @@ type Milestone struct {
 func RepoMilestonesPage(
 	qc QueryContext,
 	project *GitlabProjectInternal,
-	stopOnUpdatedAt time.Time,
+	stop *PageStop,
 	params url.Values) (pi NextPage, err error) {
 
 	sdk.LogDebug(qc.Logger, "project work sprints", "project", project.Name, "project_ref_id", project.RefID, "params", params)
 
-	objectPath := sdk.JoinURL("projects", url.QueryEscape(project.RefID), "milestones")
+	objectPath := project.APIPath("milestones")
 
-	return CommonMilestonesPage2(qc, params, stopOnUpdatedAt, objectPath, []*GitlabProjectInternal{project})
+	return CommonMilestonesPage2(qc, params, stop, objectPath, []*GitlabProjectInternal{project})
 }
 
 func GroupMilestonesPage(
 	qc QueryContext,
 	namespace *Namespace,
 	projects []*GitlabProjectInternal,
-	stopOnUpdatedAt time.Time,
+	stop *PageStop,
 	params url.Values) (pi NextPage, err error) {
 
 	sdk.LogDebug(qc.Logger, "group work sprints", "group", namespace.Name, "group_ref_id", namespace.ID, "params", params)
 
 	objectPath := sdk.JoinURL("groups", url.QueryEscape(namespace.ID), "milestones")
 
-	return CommonMilestonesPage2(qc, params, stopOnUpdatedAt, objectPath, projects)
+	return CommonMilestonesPage2(qc, params, stop, objectPath, projects)
 }
 
 func CommonMilestonesPage2(
 	qc QueryContext,
 	params url.Values,
-	stopOnUpdatedAt time.Time,
+	stop *PageStop,
 	url string,
 	repos []*GitlabProjectInternal) (pi NextPage, err error) {
 
@@ -71,7 +71,7 @@ func CommonMilestonesPage2(
 		return
 	}
 	for _, rawmilestone := range rawmilestones {
-		if rawmilestone.UpdatedAt.Before(stopOnUpdatedAt) {
+		if stop.Reached(rawmilestone.UpdatedAt) {
 			return
 		}
 
@@ -1,13 +1,8 @@
 package api
 
 import (
-	"bytes"
 	"encoding/json"
-	"net/url"
-	"strconv"
 	"time"
-
-	"github.com/pinpt/agent/v4/sdk"
 )
 
 // WebhookNote note struct comming from webhooks
@@ -26,22 +21,6 @@ type WebhookNote struct {
 // NoteDateFormat note date format
 const NoteDateFormat = "2006-01-02 15:04:05 MST"
 
-func (wn *WebhookNote) ToSourceCodePullRequestReview() *sdk.SourceCodePullRequestReview {
-
-	review := &sdk.SourceCodePullRequestReview{}
-	review.RefType = "gitlab"
-	review.RefID = strconv.FormatInt(wn.RefID, 10)
-	review.State = sdk.SourceCodePullRequestReviewStateCommented
-	review.URL = wn.URL
-	review.UserRefID = strconv.FormatInt(wn.AuthorID, 10)
-
-	t, _ := time.Parse(NoteDateFormat, wn.CreatedAt)
-
-	sdk.ConvertTimeToDateModel(t, &review.CreatedDate)
-
-	return review
-}
-
 // Note raw struct from api
 type Note struct {
 	ID     int64           `json:"id"`
@@ -53,48 +32,3 @@ type Note struct {
 	} `json:"author"`
 	CreatedAt time.Time `json:"created_at"`
 }
-
-// GetGetSinglePullRequestNote get note details to write review
-func GetGetSinglePullRequestNote(
-	qc QueryContext,
-	params url.Values,
-	projectName string,
-	projectRefID string,
-	prRefID string,
-	prIID int64,
-	username string,
-	prUpdatedAt string,
-	action string) (pi NextPage, rnote *Note, err error) {
-
-	sdk.LogDebug(qc.Logger, "pull request reviews", "project", projectName, "repo_ref_id", projectRefID, "pr_id", prRefID, "pr_iid", prIID, "params", params)
-
-	objectPath := sdk.JoinURL("projects", projectRefID, "merge_requests", strconv.FormatInt(prIID, 10), "notes")
-
-	var rnotes []*Note
-
-	pi, err = qc.Get(objectPath, params, &rnotes)
-	if err != nil {
-		return
-	}
-
-	r, err := time.Parse(NoteDateFormat, prUpdatedAt)
-	if err != nil {
-		return
-	}
-
-	for _, note := range rnotes {
-		diff1 := note.CreatedAt.Sub(r)
-		diff2 := r.Sub(note.CreatedAt)
-		if note.System == false &&
-			note.Author.Username == username &&
-			bytes.Index(note.Body, []byte(action)) == 0 &&
-			((diff1 > 0 && diff1 < (1*time.Second)) ||
-				(diff2 > 0 && diff2 < (1*time.Second))) {
-			rnote = note
-			return
-		}
-
-	}
-
-	return
-}
@@ -1,32 +1,154 @@
 package api
 
 import (
+	"fmt"
+	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 )
 
-type Page func(log sdk.Logger, params url.Values, stopOnUpdatedAt time.Time) (NextPage, error)
+const pageSize = 100
 
-func Paginate(log sdk.Logger, nextPage NextPage, lastProcessed time.Time, fn Page) (err error) {
-	if nextPage == "" {
-		nextPage = "1"
+// Page fetches a single rest page with params and returns the next page, an empty page ends the pagination
+type Page func(log sdk.Logger, params url.Values, stop *PageStop) (NextPage, error)
+
+// CursorPage fetches a single graphql page starting after the cursor and returns its page info
+type CursorPage func(log sdk.Logger, after NextPage, stop *PageStop) (PageInfo, error)
+
+// PageInfo graphql connection page info
+type PageInfo struct {
+	HasNextPage bool     `json:"hasNextPage"`
+	EndCursor   NextPage `json:"endCursor"`
+}
+
+// PageStop is the early stop hook shared by every paginator. The items must be ordered by
+// updated_at descending, once an item older than the last processed date is found the rest
+// of the page is skipped and no more pages are requested
+type PageStop struct {
+	UpdatedAt time.Time
+	stopped   bool
+}
+
+// Reached reports if the item updated at updatedAt was already processed, a nil stop never stops
+func (s *PageStop) Reached(updatedAt time.Time) bool {
+	if s == nil || s.UpdatedAt.IsZero() {
+		return false
+	}
+	if updatedAt.Before(s.UpdatedAt) {
+		s.stopped = true
 	}
-	p := url.Values{}
-	p.Set("per_page", "100")
+	return s.stopped
+}
 
+// Stopped reports if the pagination was stopped early
+func (s *PageStop) Stopped() bool {
+	return s != nil && s.stopped
+}
+
+// paginate walks the pages until fetch returns an empty page or the stop is reached
+func paginate(log sdk.Logger, page NextPage, stop *PageStop, fetch func(page NextPage) (NextPage, error)) error {
 	for {
-		p.Set("page", string(nextPage))
-		if !lastProcessed.IsZero() {
-			p.Set("order_by", "updated_at")
-		}
-		nextPage, err = fn(log, p, lastProcessed)
+		next, err := fetch(page)
 		if err != nil {
 			return err
 		}
-		if nextPage == "" {
+		if next == "" || stop.Stopped() {
 			return nil
 		}
+		if next == page {
+			return fmt.Errorf("pagination didn't advance past page %s", page)
+		}
+		sdk.LogDebug(log, "next page", "page", next)
+		page = next
+	}
+}
+
+// Paginate walks a rest collection using offset pagination starting at nextPage,
+// the items are ordered by updated_at when lastProcessed is set so the page functions
+// can stop early using the stop hook
+func Paginate(log sdk.Logger, nextPage NextPage, lastProcessed time.Time, fn Page) error {
+	if nextPage == "" {
+		nextPage = "1"
+	}
+	stop := &PageStop{UpdatedAt: lastProcessed}
+	return paginate(log, nextPage, stop, func(page NextPage) (NextPage, error) {
+		params := url.Values{}
+		params.Set("per_page", fmt.Sprint(pageSize))
+		page.apply(params)
+		if !lastProcessed.IsZero() {
+			params.Set("order_by", "updated_at")
+		}
+		return fn(log, params, stop)
+	})
+}
+
+// PaginateKeyset walks a rest collection using keyset pagination ordered by orderBy, the next page
+// is taken from the Link header. Offset pagination on gitlab stops working on large collections,
+// endpoints without keyset support answer with offset pages which are followed as well
+func PaginateKeyset(log sdk.Logger, orderBy string, fn Page) error {
+	return paginate(log, "", nil, func(page NextPage) (NextPage, error) {
+		params := url.Values{}
+		params.Set("per_page", fmt.Sprint(pageSize))
+		params.Set("pagination", "keyset")
+		params.Set("order_by", orderBy)
+		params.Set("sort", "asc")
+		page.apply(params)
+		return fn(log, params, nil)
+	})
+}
+
+// PaginateCursor walks a graphql connection following pageInfo.endCursor while hasNextPage is set,
+// the items must be ordered by updated_at descending when lastProcessed is set
+func PaginateCursor(log sdk.Logger, lastProcessed time.Time, fn CursorPage) error {
+	stop := &PageStop{UpdatedAt: lastProcessed}
+	return paginate(log, "", stop, func(after NextPage) (NextPage, error) {
+		pageInfo, err := fn(log, after, stop)
+		if err != nil {
+			return "", err
+		}
+		if !pageInfo.HasNextPage {
+			return "", nil
+		}
+		return pageInfo.EndCursor, nil
+	})
+}
+
+// apply sets the page on params, keyset pages carry the whole query of the next link
+func (np NextPage) apply(params url.Values) {
+	if np == "" {
+		return
+	}
+	if !strings.Contains(string(np), "=") {
+		params.Set("page", string(np))
+		return
+	}
+	query, err := url.ParseQuery(string(np))
+	if err != nil {
+		return
+	}
+	for k, v := range query {
+		params[k] = v
+	}
+}
+
+var linkNextRel = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="next"`)
+
+// nextPage returns the offset page from X-Next-Page or the query of the Link rel="next" for keyset pages
+func nextPage(headers http.Header) NextPage {
+	if np := headers.Get("X-Next-Page"); np != "" {
+		return NextPage(np)
+	}
+	match := linkNextRel.FindStringSubmatch(headers.Get("Link"))
+	if match == nil {
+		return ""
+	}
+	u, err := url.Parse(match[1])
+	if err != nil {
+		return ""
 	}
+	return NextPage(u.RawQuery)
 }
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextPageHeaders(t *testing.T) {
+
+	assert := assert.New(t)
+
+	headers := http.Header{}
+	headers.Set("X-Next-Page", "3")
+	headers.Set("Link", `<https://gitlab.com/api/v4/projects?page=3&per_page=100>; rel="next"`)
+	assert.Equal(NextPage("3"), nextPage(headers))
+
+	headers = http.Header{}
+	headers.Set("Link", `<https://gitlab.com/api/v4/projects?id_after=42&order_by=id&pagination=keyset&per_page=100&sort=asc>; rel="next", <https://gitlab.com/api/v4/projects?pagination=keyset>; rel="first"`)
+	assert.Equal(NextPage("id_after=42&order_by=id&pagination=keyset&per_page=100&sort=asc"), nextPage(headers))
+
+	headers = http.Header{}
+	headers.Set("Link", `<https://gitlab.com/api/v4/projects?pagination=keyset>; rel="first"`)
+	assert.Equal(NextPage(""), nextPage(headers))
+}
+
+func TestPaginateOffset(t *testing.T) {
+
+	assert := assert.New(t)
+
+	next := map[string]string{"1": "2", "2": "3", "3": ""}
+
+	var pages []string
+	err := Paginate(sdk.NewNoOpTestLogger(), "", time.Time{}, func(log sdk.Logger, params url.Values, stop *PageStop) (NextPage, error) {
+		pages = append(pages, params.Get("page"))
+		assert.Equal("100", params.Get("per_page"))
+		assert.Empty(params.Get("order_by"))
+		return NextPage(next[params.Get("page")]), nil
+	})
+	assert.NoError(err)
+	assert.Equal([]string{"1", "2", "3"}, pages)
+
+	err = Paginate(sdk.NewNoOpTestLogger(), "", time.Time{}, func(log sdk.Logger, params url.Values, stop *PageStop) (NextPage, error) {
+		return "1", nil
+	})
+	assert.Error(err, "the pagination must fail when the page doesn't advance")
+}
+
+func TestPaginateKeyset(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var calls []url.Values
+	err := PaginateKeyset(sdk.NewNoOpTestLogger(), "id", func(log sdk.Logger, params url.Values, stop *PageStop) (NextPage, error) {
+		calls = append(calls, params)
+		if len(calls) == 1 {
+			return "id_after=42&order_by=id&pagination=keyset&per_page=100&sort=asc", nil
+		}
+		return "", nil
+	})
+	assert.NoError(err)
+	assert.Len(calls, 2)
+	assert.Equal("keyset", calls[0].Get("pagination"))
+	assert.Equal("id", calls[0].Get("order_by"))
+	assert.Empty(calls[0].Get("id_after"))
+	assert.Empty(calls[0].Get("page"))
+	assert.Equal("42", calls[1].Get("id_after"))
+	assert.Equal("keyset", calls[1].Get("pagination"))
+}
+
+func TestPaginateCursor(t *testing.T) {
+
+	assert := assert.New(t)
+
+	pages := map[NextPage]PageInfo{
+		"":   {HasNextPage: true, EndCursor: "c1"},
+		"c1": {HasNextPage: true, EndCursor: "c2"},
+		"c2": {HasNextPage: false, EndCursor: "c3"},
+	}
+
+	var cursors []NextPage
+	err := PaginateCursor(sdk.NewNoOpTestLogger(), time.Time{}, func(log sdk.Logger, after NextPage, stop *PageStop) (PageInfo, error) {
+		cursors = append(cursors, after)
+		return pages[after], nil
+	})
+	assert.NoError(err)
+	assert.Equal([]NextPage{"", "c1", "c2"}, cursors)
+}
+
+func TestPaginateStopOnUpdatedAt(t *testing.T) {
+
+	assert := assert.New(t)
+
+	lastProcessed := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	updated := []time.Time{lastProcessed.Add(time.Hour), lastProcessed.Add(-time.Hour), lastProcessed.Add(time.Minute)}
+
+	var processed, calls int
+	err := PaginateCursor(sdk.NewNoOpTestLogger(), lastProcessed, func(log sdk.Logger, after NextPage, stop *PageStop) (PageInfo, error) {
+		calls++
+		for _, updatedAt := range updated {
+			if stop.Reached(updatedAt) {
+				break
+			}
+			processed++
+		}
+		return PageInfo{HasNextPage: true, EndCursor: "next"}, nil
+	})
+	assert.NoError(err)
+	assert.Equal(1, calls)
+	assert.Equal(1, processed)
+
+	var stop *PageStop
+	assert.False(stop.Reached(time.Time{}))
+
+	err = Paginate(sdk.NewNoOpTestLogger(), "", lastProcessed, func(log sdk.Logger, params url.Values, stop *PageStop) (NextPage, error) {
+		assert.Equal("updated_at", params.Get("order_by"))
+		stop.Reached(lastProcessed.Add(-time.Second))
+		return "2", nil
+	})
+	assert.NoError(err)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// Pipeline ci pipeline
+type Pipeline struct {
+	ID         int64      `json:"id"`
+	Sha        string     `json:"sha"`
+	Ref        string     `json:"ref"`
+	Status     string     `json:"status"`
+	Source     string     `json:"source"`
+	WebURL     string     `json:"web_url"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// RefID pipeline ref id
+func (p *Pipeline) RefID() string {
+	return strconv.FormatInt(p.ID, 10)
+}
+
+// ToCICDBuild convert pipeline to build
+func (p *Pipeline) ToCICDBuild(customerID, refType, repoName string) *sdk.CICDBuild {
+	build := &sdk.CICDBuild{}
+	build.ID = sdk.NewCICDBuildID(customerID, refType, p.RefID())
+	build.CustomerID = customerID
+	build.RefType = refType
+	build.RefID = p.RefID()
+	build.RepoName = repoName
+	build.CommitSha = p.Sha
+	build.URL = p.WebURL
+	build.Automated = automatedSource(p.Source)
+	build.Status = buildStatus(p.Status)
+	build.Environment = sdk.CICDBuildEnvironmentOther
+	if p.StartedAt != nil {
+		sdk.ConvertTimeToDateModel(*p.StartedAt, &build.StartDate)
+	} else {
+		sdk.ConvertTimeToDateModel(p.CreatedAt, &build.StartDate)
+	}
+	if p.FinishedAt != nil {
+		sdk.ConvertTimeToDateModel(*p.FinishedAt, &build.EndDate)
+	}
+	return build
+}
+
+// Job ci job of a pipeline
+type Job struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	WebURL     string     `json:"web_url"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Commit     struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+// RefID job ref id, pipelines and jobs are both builds and their ids can overlap
+func (j *Job) RefID() string {
+	return "job-" + strconv.FormatInt(j.ID, 10)
+}
+
+// ToCICDBuild convert job to build
+func (j *Job) ToCICDBuild(customerID, refType, repoName string) *sdk.CICDBuild {
+	build := &sdk.CICDBuild{}
+	build.ID = sdk.NewCICDBuildID(customerID, refType, j.RefID())
+	build.CustomerID = customerID
+	build.RefType = refType
+	build.RefID = j.RefID()
+	build.RepoName = repoName
+	build.CommitSha = j.Commit.ID
+	build.URL = j.WebURL
+	build.Automated = true
+	build.Status = buildStatus(j.Status)
+	build.Environment = sdk.CICDBuildEnvironmentOther
+	if j.StartedAt != nil {
+		sdk.ConvertTimeToDateModel(*j.StartedAt, &build.StartDate)
+	} else {
+		sdk.ConvertTimeToDateModel(j.CreatedAt, &build.StartDate)
+	}
+	if j.FinishedAt != nil {
+		sdk.ConvertTimeToDateModel(*j.FinishedAt, &build.EndDate)
+	}
+	return build
+}
+
+// automatedSource reports if the pipeline source isn't a user running it by hand, from the ui or a chat command
+func automatedSource(source string) bool {
+	switch source {
+	case "web", "chat":
+		return false
+	default:
+		// push, schedule, trigger, api, pipeline, parent_pipeline, merge_request_event and the others
+		return true
+	}
+}
+
+func buildStatus(status string) sdk.CICDBuildStatus {
+	switch status {
+	case "running":
+		return sdk.CICDBuildStatusRunning
+	case "success":
+		return sdk.CICDBuildStatusPass
+	case "failed":
+		return sdk.CICDBuildStatusFail
+	case "canceled", "skipped":
+		return sdk.CICDBuildStatusCancel
+	default:
+		// created, waiting_for_resource, preparing, pending, scheduled and manual
+		return sdk.CICDBuildStatusCreated
+	}
+}
+
+// PipelinesPage returns a page of the pipelines of the repo, the list doesn't have the times of the pipelines
+func PipelinesPage(qc QueryContext, repo *GitlabProjectInternal, params url.Values) (pi NextPage, pipelines []*Pipeline, err error) {
+
+	sdk.LogDebug(qc.Logger, "repo pipelines", "repo", repo.Name, "repo_ref_id", repo.RefID, "params", params)
+
+	objectPath := repo.APIPath("pipelines")
+
+	pi, err = qc.Get(objectPath, params, &pipelines)
+
+	return
+}
+
+// PipelineByID returns the pipeline with its times
+func PipelineByID(qc QueryContext, repo *GitlabProjectInternal, id int64) (pipeline *Pipeline, err error) {
+
+	sdk.LogDebug(qc.Logger, "repo pipeline", "repo", repo.Name, "repo_ref_id", repo.RefID, "pipeline_id", id)
+
+	objectPath := repo.APIPath("pipelines", strconv.FormatInt(id, 10))
+
+	_, err = qc.Get(objectPath, nil, &pipeline)
+
+	return
+}
+
+// PipelineJobsPage returns a page of the jobs of the pipeline
+func PipelineJobsPage(qc QueryContext, repo *GitlabProjectInternal, pipelineID int64, params url.Values) (pi NextPage, jobs []*Job, err error) {
+
+	sdk.LogDebug(qc.Logger, "pipeline jobs", "repo", repo.Name, "repo_ref_id", repo.RefID, "pipeline_id", pipelineID, "params", params)
+
+	objectPath := repo.APIPath("pipelines", strconv.FormatInt(pipelineID, 10), "jobs")
+
+	pi, err = qc.Get(objectPath, params, &jobs)
+
+	return
+}
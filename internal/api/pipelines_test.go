@@ -0,0 +1,22 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineToCICDBuildAutomated(t *testing.T) {
+	assert := assert.New(t)
+	for source, automated := range map[string]bool{
+		"push":                true,
+		"schedule":            true,
+		"merge_request_event": true,
+		"parent_pipeline":     true,
+		"web":                 false,
+		"chat":                false,
+	} {
+		p := &Pipeline{ID: 700, Source: source}
+		assert.Equal(automated, p.ToCICDBuild("1234", "gitlab", "acme/widgets").Automated, source)
+	}
+}
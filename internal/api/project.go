@@ -13,6 +13,15 @@ import (
 type GitlabProjectInternal struct {
 	sdk.SourceCodeRepo
 	OwnerRefID int64
+	// GitlabID is the numeric project id, the rest api is called with it
+	GitlabID int64
+	// FullPath is the project path with namespace, the graphql api is called with it
+	FullPath string
+}
+
+// APIPath returns the rest api path of the project joined with elems
+func (p *GitlabProjectInternal) APIPath(elems ...string) string {
+	return sdk.JoinURL(append([]string{"projects", strconv.FormatInt(p.GitlabID, 10)}, elems...)...)
 }
 
 // GitlabProject gitlab project
@@ -33,7 +42,7 @@ type GitlabProject struct {
 	} `json:"owner"`
 }
 
-func GroupNamespaceReposPage(qc QueryContext, namespace *Namespace, params url.Values, stopOnUpdatedAt time.Time) (page NextPage, repos []*GitlabProjectInternal, err error) {
+func GroupNamespaceReposPage(qc QueryContext, namespace *Namespace, params url.Values) (page NextPage, repos []*GitlabProjectInternal, err error) {
 
 	params.Set("with_shared", "false")
 	params.Set("include_subgroups", "true")
@@ -42,25 +51,24 @@ func GroupNamespaceReposPage(qc QueryContext, namespace *Namespace, params url.V
 
 	objectPath := sdk.JoinURL("groups", namespace.ID, "projects")
 
-	return reposCommonPage(qc, params, stopOnUpdatedAt, objectPath, sdk.SourceCodeRepoAffiliationOrganization, namespace.Name)
+	return reposCommonPage(qc, params, objectPath, sdk.SourceCodeRepoAffiliationOrganization, namespace.FullPath)
 }
 
-func UserReposPage(qc QueryContext, namespace *Namespace, params url.Values, stopOnUpdatedAt time.Time) (page NextPage, repos []*GitlabProjectInternal, err error) {
+func UserReposPage(qc QueryContext, namespace *Namespace, params url.Values) (page NextPage, repos []*GitlabProjectInternal, err error) {
 
 	sdk.LogDebug(qc.Logger, "user repos request", "namespace_path", namespace.Path, "username", namespace.Name, "params", sdk.Stringify(params))
 
 	objectPath := sdk.JoinURL("users", namespace.Path, "projects")
 
-	return reposCommonPage(qc, params, stopOnUpdatedAt, objectPath, sdk.SourceCodeRepoAffiliationUser, namespace.Name)
+	return reposCommonPage(qc, params, objectPath, sdk.SourceCodeRepoAffiliationUser, namespace.FullPath)
 }
 
 func reposCommonPage(
 	qc QueryContext,
 	params url.Values,
-	stopOnUpdatedAt time.Time,
 	objectPath string,
 	afiliation sdk.SourceCodeRepoAffiliation,
-	groupName string) (page NextPage, repos []*GitlabProjectInternal, err error) {
+	groupPath string) (page NextPage, repos []*GitlabProjectInternal, err error) {
 
 	var rr []GitlabProject
 
@@ -98,11 +106,13 @@ func reposCommonPage(
 
 		rr := &GitlabProjectInternal{}
 		rr.OwnerRefID = r.Owner.RefID
+		rr.GitlabID = r.RefID
+		rr.FullPath = r.FullName
 		rr.SourceCodeRepo = repo
 
 		qc.WorkManager.AddProjectDetails(ToProject(rr).ID, &ProjectStateInfo{
 			ProjectPath: r.FullName,
-			GroupPath:   groupName,
+			GroupPath:   groupPath,
 		})
 
 		repos = append(repos, rr)
@@ -161,7 +171,7 @@ func ProjectUser(qc QueryContext, repo *GitlabProjectInternal, userId string) (u
 
 	sdk.LogDebug(qc.Logger, "project user access level", "project_name", repo.Name, "project_id", repo.ID, "user_id", userId)
 
-	objectPath := sdk.JoinURL("projects", repo.RefID, "members", userId)
+	objectPath := repo.APIPath("members", userId)
 
 	_, err = qc.Get(objectPath, nil, &u)
 	if err != nil {
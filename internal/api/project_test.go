@@ -0,0 +1,18 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitlabProjectInternalAPIPath(t *testing.T) {
+
+	assert := assert.New(t)
+
+	project := &GitlabProjectInternal{GitlabID: 100, FullPath: "acme/widgets"}
+	project.Name = "Widgets Factory"
+
+	assert.Equal("projects/100", project.APIPath())
+	assert.Equal("projects/100/merge_requests/2/notes", project.APIPath("merge_requests", "2", "notes"))
+}
@@ -18,7 +18,7 @@ func PullRequestPage(
 
 	sdk.LogDebug(qc.Logger, "repo pull requests", "repo", repo.Name, "repo_ref_id", repo.RefID, "params", params)
 
-	objectPath := sdk.JoinURL("projects", repo.RefID, "merge_requests")
+	objectPath := repo.APIPath("merge_requests")
 
 	var rprs []apiPullRequest
 
@@ -54,6 +54,13 @@ func PullRequestPage(
 		spr := PullRequest{}
 		spr.IID = strconv.FormatInt(rpr.IID, 10)
 		spr.SourceCodePullRequest = pr
+		for _, reviewer := range rpr.Reviewers {
+			err = qc.UserManager.EmitGitUser(qc.Logger, &reviewer)
+			if err != nil {
+				return
+			}
+			spr.ReviewerRefIDs = append(spr.ReviewerRefIDs, reviewer.RefID(qc.CustomerID))
+		}
 		prs <- spr
 	}
 
@@ -17,7 +17,7 @@ func PullRequestCommentsPage(
 
 	sdk.LogDebug(qc.Logger, "pull request comments", "repo", repo.Name, "repo_ref_id", repo.RefID, "pr", pr.IID, "params", params)
 
-	objectPath := sdk.JoinURL("projects", url.QueryEscape(repo.RefID), "merge_requests", pr.IID, "notes")
+	objectPath := repo.APIPath("merge_requests", pr.IID, "notes")
 
 	var rcomments []struct {
 		ID     int64 `json:"id"`
@@ -52,7 +52,7 @@ func PullRequestCommentsPage(
 		item.CustomerID = qc.CustomerID
 		item.RefType = qc.RefType
 		item.RefID = fmt.Sprint(rcomment.ID)
-		item.URL = sdk.JoinURL(u.Scheme, "://", u.Hostname(), repo.Name, "merge_requests", pr.IID)
+		item.URL = sdk.JoinURL(u.Scheme, "://", u.Hostname(), repo.FullPath, "merge_requests", pr.IID)
 		sdk.ConvertTimeToDateModel(rcomment.UpdatedAt, &item.UpdatedDate)
 
 		item.RepoID = repoID
@@ -66,3 +66,72 @@ func PullRequestCommentsPage(
 
 	return
 }
+
+type discussionNote struct {
+	ID     int64  `json:"id"`
+	Type   string `json:"type"`
+	Author struct {
+		ID int64 `json:"id"`
+	} `json:"author"`
+	Body      string    `json:"body"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt time.Time `json:"created_at"`
+	System    bool      `json:"system"`
+}
+
+type discussion struct {
+	ID    string           `json:"id"`
+	Notes []discussionNote `json:"notes"`
+}
+
+// PullRequestDiscussionsPage returns a page of the comments of the pull request, the diff notes included
+func PullRequestDiscussionsPage(
+	qc QueryContext,
+	repo *GitlabProjectInternal,
+	pr PullRequest,
+	params url.Values) (pi NextPage, res []*sdk.SourceCodePullRequestComment, err error) {
+
+	sdk.LogDebug(qc.Logger, "pull request discussions", "repo", repo.Name, "repo_ref_id", repo.RefID, "pr", pr.IID, "params", params)
+
+	objectPath := repo.APIPath("merge_requests", pr.IID, "discussions")
+
+	var rdiscussions []discussion
+
+	pi, err = qc.Get(objectPath, params, &rdiscussions)
+	if err != nil {
+		return
+	}
+
+	u, err := url.Parse(qc.BaseURL)
+	if err != nil {
+		return pi, res, err
+	}
+
+	repoID := sdk.NewSourceCodeRepoID(qc.CustomerID, repo.RefID, qc.RefType)
+	pullRequestID := sdk.NewSourceCodePullRequestID(qc.CustomerID, pr.RefID, qc.RefType, repoID)
+
+	for _, rdiscussion := range rdiscussions {
+		for _, rcomment := range rdiscussion.Notes {
+			if rcomment.System {
+				continue
+			}
+			item := &sdk.SourceCodePullRequestComment{}
+			item.Active = true
+			item.CustomerID = qc.CustomerID
+			item.RefType = qc.RefType
+			item.RefID = fmt.Sprint(rcomment.ID)
+			item.URL = sdk.JoinURL(u.Scheme, "://", u.Hostname(), repo.FullPath, "merge_requests", pr.IID)
+			sdk.ConvertTimeToDateModel(rcomment.UpdatedAt, &item.UpdatedDate)
+
+			item.RepoID = repoID
+			item.PullRequestID = pullRequestID
+			item.Body = rcomment.Body
+			sdk.ConvertTimeToDateModel(rcomment.CreatedAt, &item.CreatedDate)
+
+			item.UserRefID = strconv.FormatInt(rcomment.Author.ID, 10)
+			res = append(res, item)
+		}
+	}
+
+	return
+}
@@ -13,6 +13,8 @@ type PullRequest struct {
 	*sdk.SourceCodePullRequest
 	IID           string
 	LastCommitSHA string
+	// ReviewerRefIDs are the users requested to review
+	ReviewerRefIDs []string
 }
 
 type CommonPullRequestFields struct {
@@ -101,12 +103,18 @@ func (a *author) ToModel(customerID string, integrationInstanceID string) *sdk.S
 	return user
 }
 
+// Reviewer reviewer of a pull request, the merge request webhooks have the same user fields as the api
+type Reviewer struct {
+	author
+}
+
 type apiPullRequest struct {
 	*CommonPullRequestFields
 	WebURL     string    `json:"web_url"`
 	Author     author    `json:"author"`
 	ClosedBy   author    `json:"closed_by"`
 	MergedBy   author    `json:"merged_by"`
+	Reviewers  []author  `json:"reviewers"`
 	UpdatedAt  time.Time `json:"updated_at"`
 	CreatedAt  time.Time `json:"created_at"`
 	References struct {
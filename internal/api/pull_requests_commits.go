@@ -52,17 +52,57 @@ func (prc *PrCommit) ToSourceCodePullRequestCommit(customerID, refType, repoID,
 	return
 }
 
+// ToSourceCodeCommit convert commit to source code commit
+func (prc *PrCommit) ToSourceCodeCommit(customerID, refType, repoID string) (scc *sdk.SourceCodeCommit) {
+
+	scc = prc.CommonCommitFields.toSourceCodeCommit(customerID, refType, repoID)
+
+	scc.URL = prc.WebURL
+	scc.AuthorRefID = CodeCommitEmail(customerID, prc.AuthorEmail)
+	scc.CommitterRefID = CodeCommitEmail(customerID, prc.CommitterEmail)
+	sdk.ConvertTimeToDateModel(prc.CreatedAt, &scc.CreatedDate)
+
+	return
+}
+
+func (cc *CommonCommitFields) toSourceCodeCommit(customerID, refType, repoID string) (scc *sdk.SourceCodeCommit) {
+
+	scc = &sdk.SourceCodeCommit{}
+	scc.ID = sdk.NewSourceCodeCommitID(customerID, cc.ID, refType, repoID)
+	scc.CustomerID = customerID
+	scc.RefType = refType
+	scc.RefID = cc.ID
+	scc.RepoID = repoID
+	scc.Active = true
+	scc.Sha = cc.ID
+	scc.Message = cc.Message
+
+	return
+}
+
 // WhCommit commit specific fields for commins in push events
 type WhCommit struct {
 	*CommonCommitFields
 	URL    string `json:"url"`
 	Author struct {
-		// Name  string `json:"name"`
+		Name  string `json:"name"`
 		Email string `json:"email"`
 	} `json:"author"`
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// ToSourceCodeCommit convert push event commit to source code commit, push events don't have the committer
+func (wc *WhCommit) ToSourceCodeCommit(customerID, refType, repoID string) (scc *sdk.SourceCodeCommit) {
+
+	scc = wc.CommonCommitFields.toSourceCodeCommit(customerID, refType, repoID)
+
+	scc.URL = wc.URL
+	scc.AuthorRefID = CodeCommitEmail(customerID, wc.Author.Email)
+	sdk.ConvertTimeToDateModel(wc.Timestamp, &scc.CreatedDate)
+
+	return
+}
+
 func (wc *WhCommit) ToSourceCodePullRequestCommit(customerID, refType, repoID, pullRequestID string) (scc *sdk.SourceCodePullRequestCommit) {
 
 	scc = wc.CommonCommitFields.commonToSourceCodeCommit(customerID, refType, repoID, pullRequestID)
@@ -80,11 +120,11 @@ func PullRequestCommitsPage(
 	repo *GitlabProjectInternal,
 	pr PullRequest,
 	params url.Values,
-	after time.Time) (pi NextPage, res []*sdk.SourceCodePullRequestCommit, err error) {
+	stop *PageStop) (pi NextPage, res []*sdk.SourceCodePullRequestCommit, err error) {
 
 	sdk.LogDebug(qc.Logger, "pull request commits", "repo", repo.Name, "repo_ref_id", repo.RefID, "pr_iid", pr.IID, "params", params)
 
-	objectPath := sdk.JoinURL("projects", repo.RefID, "merge_requests", pr.IID, "commits")
+	objectPath := repo.APIPath("merge_requests", pr.IID, "commits")
 
 	var rcommits []PrCommit
 
@@ -97,7 +137,7 @@ func PullRequestCommitsPage(
 	pullRequestID := sdk.NewSourceCodePullRequestID(qc.CustomerID, pr.RefID, qc.RefType, repoID)
 
 	for _, rcommit := range rcommits {
-		if !after.IsZero() && rcommit.CreatedAt.Before(after) {
+		if stop.Reached(rcommit.CreatedAt) {
 			return
 		}
 
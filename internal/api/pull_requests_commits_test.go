@@ -68,6 +68,7 @@ func TestWebHookPRCommitToSrcCode(t *testing.T) {
 		},
 		URL: "https://api.gitlab.com/",
 		Author: struct {
+			Name  string `json:"name"`
 			Email string `json:"email"`
 		}{
 			Email: "myemail@gmail.com",
@@ -91,3 +92,37 @@ func TestWebHookPRCommitToSrcCode(t *testing.T) {
 	assert.Equal(sdk.NewSourceCodePullRequestCommentID(customerID, whprc.ID, refType, repoID), scprc.ID)
 
 }
+
+func TestWhCommitToSourceCodeCommit(t *testing.T) {
+
+	assert := assert.New(t)
+
+	customerID := "123"
+	repoID := "w45fdc4"
+	refType := "gitlab"
+
+	at, err := time.Parse(time.RFC3339, "2020-07-20T17:28:08.571Z")
+	assert.NoError(err)
+
+	whc := &WhCommit{
+		CommonCommitFields: &CommonCommitFields{
+			ID:      "6aecf4246f0c6da6d5c3024090ad2a0b0a682e4c",
+			Message: "test",
+		},
+		URL:       "https://api.gitlab.com/",
+		Timestamp: at,
+	}
+	whc.Author.Email = "myemail@gmail.com"
+
+	scc := whc.ToSourceCodeCommit(customerID, refType, repoID)
+
+	assert.Equal(sdk.NewSourceCodeCommitID(customerID, whc.ID, refType, repoID), scc.ID)
+	assert.Equal(whc.ID, scc.RefID)
+	assert.Equal(whc.ID, scc.Sha)
+	assert.Equal(repoID, scc.RepoID)
+	assert.Equal(whc.Message, scc.Message)
+	assert.Equal(whc.URL, scc.URL)
+	assert.Equal(CodeCommitEmail(customerID, whc.Author.Email), scc.AuthorRefID)
+	assert.Equal(sdk.TimeToEpoch(whc.Timestamp), scc.CreatedDate.Epoch)
+	assert.True(scc.Active)
+}
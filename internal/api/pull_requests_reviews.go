@@ -1,105 +1,178 @@
 package api
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 )
 
-// PullRequestReviews get pr reviews
-// TODO: Fix this with updated notion docs
+const (
+	approvedNote   = "approved this merge request"
+	unapprovedNote = "unapproved this merge request"
+)
+
+// PullRequestReviewTimeline reviews of a pull request
+type PullRequestReviewTimeline struct {
+	// Reviews are the approvals and unapprovals, oldest first
+	Reviews []*sdk.SourceCodePullRequestReview
+	// Requests are the review requests of the reviewers, inactive once the reviewer approved
+	Requests []*sdk.SourceCodePullRequestReviewRequest
+}
+
+// PullRequestReviews returns the review timeline of the pull request.
+//
+// The approvals are taken from the approval system notes, which have the time of each approval and
+// unapproval, the approvals without note fall back to the time of the last approval. The review
+// requests are taken from the pull request reviewers and from the eligible approvers of the approval
+// rules requiring approvals, code owner rules included. The approval rules are only on the paid tiers,
+// they are skipped when the instance doesn't have them.
 func PullRequestReviews(
 	qc QueryContext,
 	repo *GitlabProjectInternal,
-	pr PullRequest,
-	params url.Values) (pi NextPage, res []*sdk.SourceCodePullRequestReview, err error) {
+	pr PullRequest) (timeline PullRequestReviewTimeline, err error) {
 
-	sdk.LogDebug(qc.Logger, "pull request reviews", "repo", repo.Name, "repo_ref_id", repo.RefID, "pr_iid", pr.IID, "params", params)
+	sdk.LogDebug(qc.Logger, "pull request reviews", "repo", repo.Name, "repo_ref_id", repo.RefID, "pr_iid", pr.IID)
 
-	objectPath := sdk.JoinURL("projects", repo.RefID, "merge_requests", pr.IID, "approvals")
+	repoID := sdk.NewSourceCodeRepoID(qc.CustomerID, repo.RefID, qc.RefType)
+	pullRequestID := sdk.NewSourceCodePullRequestID(qc.CustomerID, pr.RefID, qc.RefType, repoID)
 
-	var rreview struct {
-		ID         int64 `json:"id"`
+	newReview := func(refID string, userRefID string, state sdk.SourceCodePullRequestReviewState, created time.Time) *sdk.SourceCodePullRequestReview {
+		item := &sdk.SourceCodePullRequestReview{}
+		item.CustomerID = qc.CustomerID
+		item.RefType = qc.RefType
+		item.RefID = refID
+		item.RepoID = repoID
+		item.PullRequestID = pullRequestID
+		item.Active = true
+		item.State = state
+		item.UserRefID = userRefID
+		sdk.ConvertTimeToDateModel(created, &item.CreatedDate)
+		return item
+	}
+
+	err = Paginate(qc.Logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *PageStop) (NextPage, error) {
+		params.Set("sort", "asc")
+		params.Set("order_by", "created_at")
+		var rnotes []*Note
+		pi, err := qc.Get(repo.APIPath("merge_requests", pr.IID, "notes"), params, &rnotes)
+		if err != nil {
+			return pi, err
+		}
+		for _, note := range rnotes {
+			if !note.System {
+				continue
+			}
+			var body string
+			if err := json.Unmarshal(note.Body, &body); err != nil {
+				continue
+			}
+			var state sdk.SourceCodePullRequestReviewState
+			switch {
+			case strings.HasPrefix(body, approvedNote):
+				state = sdk.SourceCodePullRequestReviewStateApproved
+			case strings.HasPrefix(body, unapprovedNote):
+				state = sdk.SourceCodePullRequestReviewStateDismissed
+			default:
+				continue
+			}
+			timeline.Reviews = append(timeline.Reviews, newReview(strconv.FormatInt(note.ID, 10), strconv.FormatInt(note.Author.ID, 10), state, note.CreatedAt))
+		}
+		return pi, nil
+	})
+	if err != nil {
+		return
+	}
+
+	var rapprovals struct {
 		ApprovedBy []struct {
 			User struct {
 				ID int64 `json:"id"`
 			} `json:"user"`
 		} `json:"approved_by"`
-		SuggestedApprovers []struct {
-			UserID int64 `json:"id"`
-		} `json:"suggested_approvers"`
-		CreatedAt time.Time `json:"created_at"`
 		UpdatedAt time.Time `json:"updated_at"`
 	}
 
-	pi, err = qc.Get(objectPath, params, &rreview)
-	if err != nil {
+	if _, err = qc.Get(repo.APIPath("merge_requests", pr.IID, "approvals"), nil, &rapprovals); err != nil {
 		return
 	}
 
-	repoID := sdk.NewSourceCodeRepoID(qc.CustomerID, repo.RefID, qc.RefType)
-	pr.ID = sdk.NewSourceCodePullRequestID(qc.CustomerID, pr.RefID, qc.RefType, repoID)
-
-	for _, a := range rreview.ApprovedBy {
-		item := &sdk.SourceCodePullRequestReview{}
-		item.CustomerID = qc.CustomerID
-		item.RefType = qc.RefType
-		item.RefID = fmt.Sprint(rreview.ID)
-		item.RepoID = repoID
-		item.PullRequestID = pr.ID
-		item.Active = true
-		item.State = sdk.SourceCodePullRequestReviewStateApproved
-
-		sdk.ConvertTimeToDateModel(rreview.CreatedAt, &item.CreatedDate)
-
-		item.UserRefID = strconv.FormatInt(a.User.ID, 10)
+	// the last event of each user tells if they still approve
+	approved := make(map[string]bool)
+	for _, review := range timeline.Reviews {
+		approved[review.UserRefID] = review.State == sdk.SourceCodePullRequestReviewStateApproved
+	}
+	current := make(map[string]bool)
+	for _, a := range rapprovals.ApprovedBy {
+		userRefID := strconv.FormatInt(a.User.ID, 10)
+		current[userRefID] = true
+		if !approved[userRefID] {
+			// the note can be missing, if it was deleted or the approval imported
+			timeline.Reviews = append(timeline.Reviews, newReview(pr.RefID+"-approved-"+userRefID, userRefID, sdk.SourceCodePullRequestReviewStateApproved, rapprovals.UpdatedAt))
+		}
+	}
 
-		reviewRequest := reviewRequest(qc, pr.SourceCodePullRequest, item.UserRefID)
-		reviewRequest.Active = false
-		err = qc.Pipe.Write(&reviewRequest)
-		if err != nil {
+	requests := make(map[string]*sdk.SourceCodePullRequestReviewRequest)
+	addRequest := func(reviewerRefID string, active bool) {
+		if request := requests[reviewerRefID]; request != nil {
+			request.Active = request.Active || active
 			return
 		}
-
-		res = append(res, item)
+		request := reviewRequest(qc, pr.SourceCodePullRequest, pullRequestID, reviewerRefID)
+		request.Active = active
+		requests[reviewerRefID] = request
+		timeline.Requests = append(timeline.Requests, request)
 	}
 
-	for _, a := range rreview.SuggestedApprovers {
-		item := &sdk.SourceCodePullRequestReview{}
-		item.CustomerID = qc.CustomerID
-		item.RefType = qc.RefType
-		item.RefID = fmt.Sprint(rreview.ID)
-		item.RepoID = repoID
-		item.PullRequestID = pr.ID
-		item.Active = true
-		item.State = sdk.SourceCodePullRequestReviewStateRequested
-
-		sdk.ConvertTimeToDateModel(rreview.CreatedAt, &item.CreatedDate)
+	for _, reviewerRefID := range pr.ReviewerRefIDs {
+		addRequest(reviewerRefID, !current[reviewerRefID])
+	}
 
-		item.UserRefID = strconv.FormatInt(a.UserID, 10)
+	var rstate struct {
+		Rules []struct {
+			ID                int64 `json:"id"`
+			ApprovalsRequired int   `json:"approvals_required"`
+			Approved          bool  `json:"approved"`
+			EligibleApprovers []struct {
+				ID int64 `json:"id"`
+			} `json:"eligible_approvers"`
+		} `json:"rules"`
+	}
 
-		reviewRequest := reviewRequest(qc, pr.SourceCodePullRequest, item.UserRefID)
-		err = qc.Pipe.Write(&reviewRequest)
-		if err != nil {
-			return
+	if _, err = qc.Get(repo.APIPath("merge_requests", pr.IID, "approval_state"), nil, &rstate); err != nil {
+		if errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotFound) {
+			sdk.LogDebug(qc.Logger, "skipping approval rules, not available", "repo", repo.Name, "pr_iid", pr.IID, "err", err)
+			return timeline, nil
 		}
+		return
+	}
 
-		res = append(res, item)
+	// the approvers of a rule still missing approvals are requested until they approve
+	for _, rule := range rstate.Rules {
+		if rule.ApprovalsRequired == 0 {
+			continue
+		}
+		for _, approver := range rule.EligibleApprovers {
+			userRefID := strconv.FormatInt(approver.ID, 10)
+			addRequest(userRefID, !rule.Approved && !current[userRefID])
+		}
 	}
 
 	return
 }
 
-func reviewRequest(qc QueryContext, pr *sdk.SourceCodePullRequest, requestedReviewerID string) sdk.SourceCodePullRequestReviewRequest {
-	return sdk.SourceCodePullRequestReviewRequest{
+func reviewRequest(qc QueryContext, pr *sdk.SourceCodePullRequest, pullRequestID string, requestedReviewerID string) *sdk.SourceCodePullRequestReviewRequest {
+	return &sdk.SourceCodePullRequestReviewRequest{
 		CustomerID:             qc.CustomerID,
-		ID:                     sdk.NewSourceCodePullRequestReviewRequestID(qc.CustomerID, qc.RefType, pr.ID, requestedReviewerID),
+		ID:                     sdk.NewSourceCodePullRequestReviewRequestID(qc.CustomerID, qc.RefType, pullRequestID, requestedReviewerID),
+		RefID:                  pr.RefID + "-request-" + requestedReviewerID,
 		RefType:                qc.RefType,
 		RepoID:                 pr.RepoID,
-		PullRequestID:          pr.ID,
+		PullRequestID:          pullRequestID,
 		Active:                 true,
 		CreatedDate:            sdk.SourceCodePullRequestReviewRequestCreatedDate(pr.UpdatedDate),
 		IntegrationInstanceID:  sdk.StringPointer(qc.IntegrationInstanceID),
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+const (
+	// rateLimitSlowDownRatio is the fraction of the quota below which requests start to be spread until the reset time
+	rateLimitSlowDownRatio = 0.1
+	// defaultRetryAfter is used when gitlab throttles a request without telling us for how long
+	defaultRetryAfter = time.Minute
+)
+
+// RateLimiter keeps track of the gitlab rate limit budget using the
+// RateLimit-* and Retry-After response headers, it is shared between
+// the rest and graphql requesters since both consume the same quota
+type RateLimiter struct {
+	logger    sdk.Logger
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	resetAt   time.Time
+	known     bool
+	now       func() time.Time
+	sleep     func(context.Context, time.Duration) error
+}
+
+// NewRateLimiter new rate limiter
+func NewRateLimiter(logger sdk.Logger) *RateLimiter {
+	return &RateLimiter{
+		logger: logger,
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+// Wait blocks until the budget allows a new request, it sleeps until the reset time
+// when the quota is exhausted and spreads the requests when it is about to run out.
+// It returns the context error if the context is cancelled while waiting
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	wait := r.waitTime()
+	if r.known && r.remaining > 0 {
+		r.remaining--
+	}
+	r.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	paused := r.now()
+	sdk.LogWarn(r.logger, "gitlab rate limit almost reached, waiting", "wait", wait.String(), "resume", paused.Add(wait).String())
+	if err := r.sleep(ctx, wait); err != nil {
+		return err
+	}
+	sdk.LogDebug(r.logger, "gitlab rate limit resumed", "elapsed", r.now().Sub(paused).String())
+	return nil
+}
+
+func (r *RateLimiter) waitTime() time.Duration {
+	if !r.known {
+		return 0
+	}
+	untilReset := r.resetAt.Sub(r.now())
+	if untilReset <= 0 {
+		// the window has been reset, we don't know the new budget until the next response
+		r.known = false
+		return 0
+	}
+	if r.remaining <= 0 {
+		return untilReset
+	}
+	if r.limit > 0 && float64(r.remaining) < float64(r.limit)*rateLimitSlowDownRatio {
+		return untilReset / time.Duration(r.remaining+1)
+	}
+	return 0
+}
+
+// Update updates the budget from the response headers
+func (r *RateLimiter) Update(headers http.Header) {
+	if headers == nil {
+		return
+	}
+	remaining, err := strconv.Atoi(headers.Get("RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remaining = remaining
+	if limit, err := strconv.Atoi(headers.Get("RateLimit-Limit")); err == nil {
+		r.limit = limit
+	}
+	if reset, err := strconv.ParseInt(headers.Get("RateLimit-Reset"), 10, 64); err == nil {
+		r.resetAt = time.Unix(reset, 0)
+	}
+	r.known = true
+}
+
+// Throttled records that gitlab throttled a request, following requests will wait retryAfter
+func (r *RateLimiter) Throttled(retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	resetAt := r.now().Add(retryAfter)
+	if !r.known || resetAt.After(r.resetAt) {
+		r.resetAt = resetAt
+	}
+	r.remaining = 0
+	r.known = true
+}
+
+// responseOption returns an http option which updates the budget with every response
+// received, including the throttled ones the http client retries by itself
+func (r *RateLimiter) responseOption(throttled *bool) sdk.WithHTTPOption {
+	return func(opt *sdk.HTTPOptions) error {
+		if opt.Response == nil {
+			return nil
+		}
+		r.Update(opt.Response.Headers)
+		if opt.Response.StatusCode == http.StatusTooManyRequests {
+			*throttled = true
+			r.Throttled(retryAfter(opt.Response.Headers))
+		}
+		return nil
+	}
+}
+
+// retryAfter parses the Retry-After header which can be either seconds or an http date
+func retryAfter(headers http.Header) time.Duration {
+	val := headers.Get("Retry-After")
+	if val == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(val); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if date, err := http.ParseTime(val); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRateLimiter(now time.Time) (*RateLimiter, *time.Duration) {
+	var slept time.Duration
+	r := NewRateLimiter(sdk.NewNoOpTestLogger())
+	r.now = func() time.Time { return now }
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+	return r, &slept
+}
+
+func TestRateLimiterWaitsUntilReset(t *testing.T) {
+
+	assert := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	r, slept := newTestRateLimiter(now)
+
+	assert.NoError(r.Wait(context.Background()))
+	assert.Equal(time.Duration(0), *slept)
+
+	headers := http.Header{}
+	headers.Set("RateLimit-Limit", "600")
+	headers.Set("RateLimit-Remaining", "0")
+	headers.Set("RateLimit-Reset", strconv.FormatInt(now.Add(30*time.Second).Unix(), 10))
+	r.Update(headers)
+
+	assert.NoError(r.Wait(context.Background()))
+	assert.Equal(30*time.Second, *slept)
+}
+
+func TestRateLimiterSlowsDown(t *testing.T) {
+
+	assert := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	r, slept := newTestRateLimiter(now)
+
+	headers := http.Header{}
+	headers.Set("RateLimit-Limit", "600")
+	headers.Set("RateLimit-Remaining", "300")
+	headers.Set("RateLimit-Reset", strconv.FormatInt(now.Add(time.Minute).Unix(), 10))
+	r.Update(headers)
+
+	assert.NoError(r.Wait(context.Background()))
+	assert.Equal(time.Duration(0), *slept)
+
+	headers.Set("RateLimit-Remaining", "9")
+	r.Update(headers)
+
+	assert.NoError(r.Wait(context.Background()))
+	assert.Equal(6*time.Second, *slept)
+}
+
+func TestRateLimiterThrottled(t *testing.T) {
+
+	assert := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	r, slept := newTestRateLimiter(now)
+
+	r.Throttled(0)
+	assert.NoError(r.Wait(context.Background()))
+	assert.Equal(defaultRetryAfter, *slept)
+
+	headers := http.Header{}
+	headers.Set("Retry-After", "20")
+	assert.Equal(20*time.Second, retryAfter(headers))
+}
@@ -2,28 +2,34 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 )
 
 // Requester requester
 type Requester struct {
+	ctx         context.Context
 	logger      sdk.Logger
 	concurrency chan bool
 	client      sdk.HTTPClient
+	rateLimiter *RateLimiter
+	retryPolicy RetryPolicy
 }
 
-// NewRequester new requester
-func NewRequester(logger sdk.Logger, client sdk.HTTPClient, concurrency int) *Requester {
+// NewRequester new requester, outstanding requests are cancelled once ctx is done
+func NewRequester(ctx context.Context, logger sdk.Logger, client sdk.HTTPClient, rateLimiter *RateLimiter, retryPolicy RetryPolicy, concurrency int) *Requester {
 	return &Requester{
+		ctx:         ctx,
 		logger:      logger,
 		client:      client,
+		rateLimiter: rateLimiter,
+		retryPolicy: retryPolicy,
 		concurrency: make(chan bool, concurrency),
 	}
 }
@@ -37,6 +43,20 @@ const (
 	Put
 )
 
+func (r requestType) String() string {
+	switch r {
+	case Get:
+		return http.MethodGet
+	case Post:
+		return http.MethodPost
+	case Delete:
+		return http.MethodDelete
+	case Put:
+		return http.MethodPut
+	}
+	return "unknown"
+}
+
 type internalRequest struct {
 	EndPoint    string
 	Params      url.Values
@@ -73,7 +93,7 @@ func (e *Requester) Get(endpoint string, params url.Values, response interface{}
 		RequestType: Get,
 	}
 
-	return e.makeRequestRetry(&ir, 0)
+	return e.makeRequestRetry(&ir)
 
 }
 
@@ -91,7 +111,7 @@ func (e *Requester) Delete(endpoint string, params url.Values, response interfac
 		RequestType: Delete,
 	}
 
-	return e.makeRequestRetry(&ir, 0)
+	return e.makeRequestRetry(&ir)
 
 }
 
@@ -110,7 +130,7 @@ func (e *Requester) Post(endpoint string, params url.Values, data io.Reader, res
 		RequestType: Post,
 	}
 
-	return e.makeRequestRetry(&ir, 0)
+	return e.makeRequestRetry(&ir)
 
 }
 
@@ -129,112 +149,116 @@ func (e *Requester) Put(endpoint string, params url.Values, data io.Reader, resp
 		RequestType: Put,
 	}
 
-	return e.makeRequestRetry(&ir, 0)
+	return e.makeRequestRetry(&ir)
 
 }
 
-const maxGeneralRetries = 2
-
-func (e *Requester) makeRequestRetry(req *internalRequest, generalRetry int) (np NextPage, err error) {
-	var isRetryable bool
-	isRetryable, np, err = e.request(req, generalRetry+1)
-	if err != nil {
-		if !isRetryable {
-			return np, err
+func (e *Requester) makeRequestRetry(req *internalRequest) (np NextPage, err error) {
+	var retries, throttledRetries int
+	for {
+		var isRetryable bool
+		isRetryable, np, err = e.request(req, retries+throttledRetries)
+		if err == nil || !isRetryable {
+			return
 		}
-		if generalRetry >= maxGeneralRetries {
-			return np, fmt.Errorf(`can't retry request, too many retries, err: %v`, err)
+		if IsRateLimited(err) {
+			if throttledRetries >= e.retryPolicy.MaxThrottledRetries {
+				return np, fmt.Errorf(`can't retry request, too many throttled retries, err: %w`, err)
+			}
+			// the rate limiter waits for the reset before the next attempt
+			throttledRetries++
+			continue
+		}
+		if retries >= e.retryPolicy.MaxRetries {
+			return np, fmt.Errorf(`can't retry request, too many retries, err: %w`, err)
+		}
+		if err := sleepContext(e.ctx, e.retryPolicy.Backoff(retries)); err != nil {
+			return np, err
 		}
-		return e.makeRequestRetry(req, generalRetry+1)
+		retries++
 	}
-	return
 }
 
-const maxThrottledRetries = 3
-
-func (e *Requester) request(r *internalRequest, retryThrottled int) (isErrorRetryable bool, np NextPage, rerr error) {
+func (e *Requester) request(r *internalRequest, retry int) (isErrorRetryable bool, np NextPage, rerr error) {
 
 	headers := sdk.WithHTTPHeader("Accept", "application/json")
 	endpoint := sdk.WithEndpoint(r.EndPoint)
 	parameters := sdk.WithGetQueryParameters(r.Params)
 
+	withContext := withHTTPContext(e.ctx)
+
+	var throttled bool
+	rateLimit := e.rateLimiter.responseOption(&throttled)
+
+	rateLimited := func() (isErrorRetryable bool, NextPage NextPage, rerr error) {
+		sdk.LogWarn(e.logger, "api request failed due to throttling, will wait for the rate limit reset and retry", "endpoint", r.EndPoint, "retry", retry)
+		return true, np, &Error{
+			StatusCode: http.StatusTooManyRequests,
+			Method:     r.RequestType.String(),
+			Endpoint:   r.EndPoint,
+			Message:    "too many requests",
+			Retryable:  true,
+		}
+	}
+
+	if err := e.rateLimiter.Wait(e.ctx); err != nil {
+		return false, np, err
+	}
+
 	sdk.LogDebug(e.logger, "request info", "method", r.RequestType, "endpoint", r.EndPoint, "parameters", r.Params)
 
 	var resp *sdk.HTTPResponse
 	switch r.RequestType {
 	case Get:
-		resp, rerr = e.client.Get(&r.Response, headers, endpoint, parameters)
-		if rerr != nil {
-			return true, np, fmt.Errorf("error on get: %s %s", rerr, string(resp.Body))
-		}
+		resp, rerr = e.client.Get(&r.Response, headers, endpoint, parameters, withContext, rateLimit)
 	case Post:
 		reader, err := r.getDataReader()
 		if err != nil {
-			sdk.LogDebug(e.logger, "request response", "resp", string(resp.Body))
-			return true, np, fmt.Errorf("error on post: %s", err)
-		}
-		resp, rerr = e.client.Post(reader, &r.Response, headers, endpoint, parameters)
-		if rerr != nil {
-			return true, np, fmt.Errorf("error on post: %s %s", rerr, string(resp.Body))
+			return false, np, fmt.Errorf("error reading post data: %w", err)
 		}
+		resp, rerr = e.client.Post(reader, &r.Response, headers, endpoint, parameters, withContext, rateLimit)
 	case Delete:
-		resp, rerr = e.client.Delete(&r.Response, headers, endpoint, parameters)
-		if rerr != nil {
-			return true, np, fmt.Errorf("error on delete: %s %s", rerr, string(resp.Body))
-		}
+		resp, rerr = e.client.Delete(&r.Response, headers, endpoint, parameters, withContext, rateLimit)
 	case Put:
 		reader, err := r.getDataReader()
 		if err != nil {
-			sdk.LogDebug(e.logger, "request response", "resp", string(resp.Body))
-			return true, np, fmt.Errorf("error on put: %s", err)
+			return false, np, fmt.Errorf("error reading put data: %w", err)
 		}
-		resp, rerr = e.client.Put(reader, &r.Response, headers, endpoint, parameters)
-		if rerr != nil {
-			return true, np, fmt.Errorf("error on put: %s %s", rerr, string(resp.Body))
-		}
-	}
-
-	rateLimited := func() (isErrorRetryable bool, NextPage NextPage, rerr error) {
-
-		rateLimit(e.logger, retryThrottled)
-
-		return true, np, fmt.Errorf("too many requests")
-
+		resp, rerr = e.client.Put(reader, &r.Response, headers, endpoint, parameters, withContext, rateLimit)
 	}
 
-	if resp.StatusCode != http.StatusOK &&
+	if rerr == nil && resp != nil && resp.StatusCode != http.StatusOK &&
 		resp.StatusCode != http.StatusCreated &&
 		resp.StatusCode != http.StatusNoContent {
+		rerr = fmt.Errorf("request with status %d", resp.StatusCode)
+	}
 
-		if resp.StatusCode == http.StatusTooManyRequests {
+	if rerr != nil {
+		if err := e.ctx.Err(); err != nil {
+			return false, np, err
+		}
+		if throttled {
 			return rateLimited()
 		}
-
-		if resp.StatusCode == http.StatusForbidden {
-
-			return false, np, fmt.Errorf("permissions error")
+		apiErr := newRequestError(r, resp, rerr)
+		if apiErr.StatusCode == http.StatusTooManyRequests {
+			return rateLimited()
 		}
-
-		sdk.LogWarn(e.logger, "gitlab returned invalid status code, retrying", "code", resp.StatusCode, "retry", retryThrottled)
-
-		return true, np, fmt.Errorf("request with status %d", resp.StatusCode)
+		if apiErr.Retryable {
+			sdk.LogWarn(e.logger, "gitlab request failed, retrying", "code", apiErr.StatusCode, "endpoint", r.EndPoint, "retry", retry, "err", apiErr.Message)
+		}
+		return apiErr.Retryable, np, apiErr
 	}
 
-	return false, NextPage(resp.Headers.Get("X-Next-Page")), nil
+	return false, nextPage(resp.Headers), nil
 }
 
-func rateLimit(logger sdk.Logger, retryThrottled int) {
-
-	waitTime := time.Minute * 3
-
-	sdk.LogWarn(logger, "api request failed due to throttling, the quota of 600 calls has been reached, will sleep for 3m and retry", "retryThrottled", retryThrottled)
-
-	paused := time.Now()
-	resumeDate := paused.Add(waitTime)
-	sdk.LogWarn(logger, "gitlab paused, it will resume in %s, resume data %s", waitTime.String(), resumeDate.String())
-
-	time.Sleep(waitTime)
-
-	sdk.LogWarn(logger, fmt.Sprintf("gitlab resumed, time elapsed %v", time.Since(paused)))
-
+// withHTTPContext binds the request to the context so it is aborted once the context is done
+func withHTTPContext(ctx context.Context) sdk.WithHTTPOption {
+	return func(opt *sdk.HTTPOptions) error {
+		if opt.Response == nil {
+			opt.Request = opt.Request.WithContext(ctx)
+		}
+		return nil
+	}
 }
@@ -11,14 +11,13 @@ func getOpenCloseIssueHistoryPage(
 	qc QueryContext,
 	project *GitlabProjectInternal,
 	issueIID string,
-	stopOnUpdatedAt time.Time,
 	params url.Values) (pi NextPage, rse []*ResourceStateEvents, err error) {
 
 	sdk.LogDebug(qc.Logger, "work issue resource_state_events", "project", project.RefID)
 
-	objectPath := sdk.JoinURL("projects", url.QueryEscape(project.RefID), "issues", issueIID, "resource_state_events")
+	objectPath := project.APIPath("issues", issueIID, "resource_state_events")
 
-	pi, err = qc.Get(objectPath, nil, &rse)
+	pi, err = qc.Get(objectPath, params, &rse)
 	if err != nil {
 		return
 	}
@@ -32,8 +31,8 @@ func GetOpenClosedIssueHistory(
 	project *GitlabProjectInternal,
 	issueIID string) (rse []*ResourceStateEvents, err error) {
 
-	err = Paginate(qc.Logger, "", time.Time{}, func(log sdk.Logger, params url.Values, stopOnUpdatedAt time.Time) (NextPage, error) {
-		np, arr, err := getOpenCloseIssueHistoryPage(qc, project, issueIID, stopOnUpdatedAt, params)
+	err = Paginate(qc.Logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *PageStop) (NextPage, error) {
+		np, arr, err := getOpenCloseIssueHistoryPage(qc, project, issueIID, params)
 		if err != nil {
 			return np, err
 		}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how failed api requests are retried
+type RetryPolicy struct {
+	// MaxRetries max number of retries for server and network errors
+	MaxRetries int
+	// MaxThrottledRetries max number of retries for throttled requests, the wait time is driven by the rate limiter
+	MaxThrottledRetries int
+	// InitialBackoff wait time before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff max wait time between retries
+	MaxBackoff time.Duration
+	// Multiplier factor applied to the wait time after every retry
+	Multiplier float64
+	// Jitter fraction of the wait time which is randomized, between 0 and 1
+	Jitter float64
+}
+
+// DefaultRetryPolicy default retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:          3,
+		MaxThrottledRetries: 3,
+		InitialBackoff:      time.Second,
+		MaxBackoff:          30 * time.Second,
+		Multiplier:          2,
+		Jitter:              0.5,
+	}
+}
+
+// Backoff returns the wait time before the retry number attempt, starting from 0
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt))
+	if max := float64(p.MaxBackoff); p.MaxBackoff > 0 && backoff > max {
+		backoff = max
+	}
+	if p.Jitter > 0 {
+		jitter := backoff * math.Min(p.Jitter, 1)
+		backoff = backoff - jitter + rand.Float64()*jitter
+	}
+	return time.Duration(backoff)
+}
+
+// isStatusRetryable returns true if a request which failed with this status can be retried,
+// status 0 means the request didn't get a response because of a network error
+func isStatusRetryable(statusCode int) bool {
+	switch {
+	case statusCode == 0,
+		statusCode == http.StatusRequestTimeout,
+		statusCode == http.StatusTooManyRequests,
+		statusCode >= http.StatusInternalServerError:
+		return true
+	}
+	return false
+}
+
+// sleepContext sleeps the duration or until the context is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
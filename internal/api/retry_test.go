@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+
+	assert := assert.New(t)
+
+	policy := RetryPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+
+	assert.Equal(time.Second, policy.Backoff(0))
+	assert.Equal(2*time.Second, policy.Backoff(1))
+	assert.Equal(4*time.Second, policy.Backoff(2))
+	assert.Equal(5*time.Second, policy.Backoff(3))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(1)
+		assert.True(backoff >= time.Second && backoff <= 2*time.Second)
+	}
+}
+
+func TestIsStatusRetryable(t *testing.T) {
+
+	assert := assert.New(t)
+
+	assert.True(isStatusRetryable(0))
+	assert.True(isStatusRetryable(http.StatusTooManyRequests))
+	assert.True(isStatusRetryable(http.StatusInternalServerError))
+	assert.True(isStatusRetryable(http.StatusBadGateway))
+	assert.False(isStatusRetryable(http.StatusBadRequest))
+	assert.False(isStatusRetryable(http.StatusForbidden))
+	assert.False(isStatusRetryable(http.StatusNotFound))
+}
+
+func TestSleepContextCancelled(t *testing.T) {
+
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(context.Canceled, sleepContext(ctx, time.Hour))
+}
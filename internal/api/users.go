@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 )
@@ -24,7 +25,7 @@ func RepoUsersPage(qc QueryContext, repo *GitlabProjectInternal, params url.Valu
 
 	sdk.LogDebug(qc.Logger, "users request", "repo", repo.Name, "repo_ref_id", repo.RefID, "params", params)
 
-	objectPath := sdk.JoinURL("projects", url.QueryEscape(repo.RefID), "users")
+	objectPath := repo.APIPath("users")
 
 	var ru []struct {
 		ID        int64  `json:"id"`
@@ -106,6 +107,34 @@ func UserByID(qc QueryContext, userID int64) (user *sdk.SourceCodeUser, err erro
 	return
 }
 
+// Membership a group or project the user is a member of
+type Membership struct {
+	SourceID    int64  `json:"source_id"`
+	SourceName  string `json:"source_name"`
+	SourceType  string `json:"source_type"`
+	AccessLevel int64  `json:"access_level"`
+}
+
+// UserMemberships returns the groups and projects the user is a member of, only admins can call it
+func UserMemberships(qc QueryContext, userID int64) (memberships []Membership, err error) {
+
+	sdk.LogDebug(qc.Logger, "user memberships request", "user_id", userID)
+
+	objectPath := sdk.JoinURL("users", strconv.FormatInt(userID, 10), "memberships")
+
+	err = Paginate(qc.Logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *PageStop) (NextPage, error) {
+		var page []Membership
+		pi, err := qc.Get(objectPath, params, &page)
+		if err != nil {
+			return pi, err
+		}
+		memberships = append(memberships, page...)
+		return pi, nil
+	})
+
+	return
+}
+
 type GitlabUser struct {
 	RefID       int64  `json:"id"`
 	Name        string `json:"name"`
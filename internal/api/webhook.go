@@ -1,7 +1,10 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"strings"
 
@@ -33,28 +36,60 @@ var webHookParams map[sdk.WebHookScope]url.Values = map[sdk.WebHookScope]url.Val
 		"merge_requests_events": []string{"true"},
 		"note_events":           []string{"true"},
 		"issues_events":         []string{"true"},
+		"push_events":           []string{"true"},
+		"pipeline_events":       []string{"true"},
+		"job_events":            []string{"true"},
+		"deployment_events":     []string{"true"},
 	},
 	sdk.WebHookScopeRepo: {
 		"merge_requests_events": []string{"true"},
 		"note_events":           []string{"true"},
 		"issues_events":         []string{"true"},
+		"push_events":           []string{"true"},
+		"pipeline_events":       []string{"true"},
+		"job_events":            []string{"true"},
+		"deployment_events":     []string{"true"},
 	},
 }
 
-// CreateWebHook create
-func CreateWebHook(whType sdk.WebHookScope, qc QueryContext, eventAPIWebhookURL, entityID, entityName string) error {
+// CreateWebHook create, gitlab sends the token in the X-Gitlab-Token header of the deliveries
+func CreateWebHook(whType sdk.WebHookScope, qc QueryContext, eventAPIWebhookURL, entityID, entityName, token string) error {
 
 	sdk.LogInfo(qc.Logger, fmt.Sprintf("create %s webhooks", whType), "entityID", entityID, "entityName", entityName)
 
 	objectPath := buildPath(whType, entityID)
 
-	params := webHookParams[whType]
-	params.Set("url", eventAPIWebhookURL)
-	params.Set("enable_ssl_verification", "true")
+	var resp interface{}
+
+	body, err := webHookBody(whType, eventAPIWebhookURL, token)
+	if err != nil {
+		return err
+	}
+
+	_, err = qc.Post(objectPath, nil, body, &resp)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateWebHook sets the url, events and token of a hook back to the ones it was created with,
+// gitlab can't update the system hooks
+func UpdateWebHook(whType sdk.WebHookScope, qc QueryContext, eventAPIWebhookURL, entityID, entityName, whID, token string) error {
+
+	sdk.LogInfo(qc.Logger, fmt.Sprintf("update webhook %s", whType), "entityID", entityID, "entityName", entityName, "webhookID", whID)
+
+	objectPath := buildHookPath(whType, entityID, whID)
 
 	var resp interface{}
 
-	_, err := qc.Post(objectPath, params, strings.NewReader(""), &resp)
+	body, err := webHookBody(whType, eventAPIWebhookURL, token)
+	if err != nil {
+		return err
+	}
+
+	_, err = qc.Put(objectPath, nil, body, &resp)
 	if err != nil {
 		return err
 	}
@@ -62,10 +97,73 @@ func CreateWebHook(whType sdk.WebHookScope, qc QueryContext, eventAPIWebhookURL,
 	return nil
 }
 
+// webHookBody returns the hook settings as a json body, the token is a secret and the query
+// parameters are logged with the request
+func webHookBody(whType sdk.WebHookScope, eventAPIWebhookURL, token string) (io.Reader, error) {
+	hook := map[string]interface{}{
+		"url":                     eventAPIWebhookURL,
+		"enable_ssl_verification": true,
+		"token":                   token,
+	}
+	for k := range webHookParams[whType] {
+		hook[k] = true
+	}
+	bts, err := json.Marshal(hook)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(bts), nil
+}
+
 // GitlabWebhook webhook object
 type GitlabWebhook struct {
-	ID  int64  `json:"id"`
-	URL string `json:"url"`
+	ID                    int64  `json:"id"`
+	URL                   string `json:"url"`
+	EnableSSLVerification *bool  `json:"enable_ssl_verification"`
+	// AlertStatus is executable until gitlab disables the hook after failed deliveries, older
+	// instances don't send it
+	AlertStatus string `json:"alert_status"`
+	// Events are the *_events params of the hook
+	Events map[string]bool `json:"-"`
+}
+
+// UnmarshalJSON decodes the hook and its events
+func (wh *GitlabWebhook) UnmarshalJSON(b []byte) error {
+	type hook GitlabWebhook
+	if err := json.Unmarshal(b, (*hook)(wh)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	wh.Events = make(map[string]bool)
+	for k, v := range fields {
+		var enabled bool
+		if strings.HasSuffix(k, "_events") && json.Unmarshal(v, &enabled) == nil {
+			wh.Events[k] = enabled
+		}
+	}
+	return nil
+}
+
+// Disabled returns true if gitlab stopped sending the deliveries of the hook
+func (wh *GitlabWebhook) Disabled() bool {
+	return wh.AlertStatus == "disabled" || wh.AlertStatus == "temporarily_disabled"
+}
+
+// Drifted returns true if the hook lost any of the events or settings the hooks of its scope are
+// created with. Older instances don't send some of them, only the ones sent disabled are drift
+func (wh *GitlabWebhook) Drifted(whType sdk.WebHookScope) bool {
+	if wh.EnableSSLVerification != nil && !*wh.EnableSSLVerification {
+		return true
+	}
+	for k := range webHookParams[whType] {
+		if enabled, ok := wh.Events[k]; ok && !enabled {
+			return true
+		}
+	}
+	return false
 }
 
 // GetWebHooksPage get web-hooks page
@@ -101,7 +199,7 @@ func DeleteWebHook(whType sdk.WebHookScope, qc QueryContext, entityID, entityNam
 
 	sdk.LogInfo(qc.Logger, fmt.Sprintf("delete webhook %s", whType), "entityID", entityID, "entityName", entityName, "webhookID", whID)
 
-	objectPath := buildDeletePath(whType, entityID, whID)
+	objectPath := buildHookPath(whType, entityID, whID)
 
 	var resp interface{}
 
@@ -113,7 +211,8 @@ func DeleteWebHook(whType sdk.WebHookScope, qc QueryContext, entityID, entityNam
 	return nil
 }
 
-func buildDeletePath(whType sdk.WebHookScope, entityID string, whID string) string {
+// buildHookPath returns the path of a single hook
+func buildHookPath(whType sdk.WebHookScope, entityID string, whID string) string {
 
 	var path string
 
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookDrifted(t *testing.T) {
+
+	assert := assert.New(t)
+
+	drifted := func(body string) bool {
+		var wh GitlabWebhook
+		assert.NoError(json.Unmarshal([]byte(body), &wh))
+		return wh.Drifted(sdk.WebHookScopeRepo)
+	}
+
+	assert.False(drifted(`{"id":1,"enable_ssl_verification":true,"merge_requests_events":true}`))
+	assert.True(drifted(`{"id":1,"enable_ssl_verification":true,"merge_requests_events":false}`))
+	assert.True(drifted(`{"id":1,"enable_ssl_verification":false}`))
+
+	// the settings older instances don't send aren't drift
+	assert.False(drifted(`{"id":1}`))
+}
@@ -33,7 +33,7 @@ func WorkIssuesDiscussionPage(qc QueryContext, project *GitlabProjectInternal, i
 	}
 	issueIID := issue.Identifier[index+1:]
 
-	objectPath := sdk.JoinURL("projects", url.QueryEscape(project.RefID), "issues", issueIID, "discussions.json")
+	objectPath := project.APIPath("issues", issueIID, "discussions.json")
 
 	var notes []struct {
 		ID    string `json:"id"`
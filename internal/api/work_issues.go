@@ -66,9 +66,9 @@ type Issue2 struct {
 	DueDate   interface{} `json:"dueDate"`
 }
 
-const issuesQuery = `query {
-	project(fullPath:"%s"){
-		issues(first:100,after:"%s"){
+var issuesQuery = newGraphqlOperation("ProjectIssues", `query ProjectIssues($fullPath: ID!, $after: String, $updatedAfter: Time, $sort: IssueSort) {
+	project(fullPath:$fullPath){
+		issues(first:100,after:$after,updatedAfter:$updatedAfter,sort:$sort){
 			pageInfo{
 				hasNextPage
 				endCursor
@@ -129,7 +129,7 @@ const issuesQuery = `query {
 			}
 		}
 	}
-  }`
+  }`)
 
 type UserModel struct {
 	Username  string `json:"username"`
@@ -203,8 +203,8 @@ const MilestoneIssueType = "Milestone"
 
 func WorkSingleIssue(
 	qc QueryContext,
-	project *sdk.SourceCodeRepo,
-	stopOnUpdatedAt time.Time,
+	project *GitlabProjectInternal,
+	stop *PageStop,
 	params url.Values,
 	issues chan *sdk.WorkIssue) (pi NextPage, err error) {
 
@@ -213,7 +213,7 @@ func WorkSingleIssue(
 
 	sdk.LogDebug(qc.Logger, "work issues", "project", project.Name, "project_ref_id", project.RefID, "params", params)
 
-	objectPath := sdk.JoinURL("projects", url.QueryEscape(project.RefID), "issues")
+	objectPath := project.APIPath("issues")
 
 	var rawissues []IssueModel
 
@@ -225,72 +225,65 @@ func WorkSingleIssue(
 	sdk.LogDebug(qc.Logger, "issues found", "len", len(rawissues))
 
 	for _, rawissue := range rawissues {
-		if rawissue.UpdatedAt.Before(stopOnUpdatedAt) {
+		if stop.Reached(rawissue.UpdatedAt) {
 			return
 		}
 
-		issues <- rawissue.ToModel(qc, project.RefID, project.Name)
+		issues <- rawissue.ToModel(qc, project.RefID, project.FullPath)
 	}
 
 	return
 }
 
-// WorkIssuesPage graphql issue page
+// WorkIssuesPage graphql issue page, when updatedAfter is set only the issues updated after it are fetched,
+// most recently updated first
 func WorkIssuesPage(
 	qc QueryContext,
 	project *GitlabProjectInternal,
-	nextPageP NextPage,
-	issues chan *sdk.WorkIssue) (nextPage NextPage, err error) {
+	updatedAfter time.Time,
+	after NextPage,
+	stop *PageStop,
+	issues chan *sdk.WorkIssue) (pageInfo PageInfo, err error) {
 
 	sdk.LogDebug(qc.Logger, "work issues", "project", project.Name, "project_ref_id", project.RefID)
 
 	var Data struct {
 		Project struct {
 			Issues struct {
-				PageInfo struct {
-					HasNextPage bool   `json:"hasNextPage"`
-					EndCursor   string `json:"endCursor"`
-				} `json:"pageInfo"`
-				Count int `json:"count"`
-				Edges []struct {
+				PageInfo PageInfo `json:"pageInfo"`
+				Count    int      `json:"count"`
+				Edges    []struct {
 					Node Issue2 `json:"node"`
 				} `json:"edges"`
 			} `json:"issues"`
 		} `json:"project"`
-		Error            string `json:"error"`
-		ErrorDescription string `json:"error_description"`
-		Scope            string `json:"scope"`
 	}
 
-	query := fmt.Sprintf(issuesQuery, project.Name, nextPageP)
-
-	err = qc.GraphRequester.Query(query, nil, &Data)
-	if err != nil {
-		return
+	variables := graphqlVariables{"fullPath": project.FullPath}.cursor("after", after)
+	if !updatedAfter.IsZero() {
+		variables["updatedAfter"] = updatedAfter.UTC().Format(time.RFC3339)
+		variables["sort"] = "UPDATED_DESC"
 	}
 
-	if Data.Error != "" {
-		err = fmt.Errorf("error getting issues, err %s, project %s, project_ref_id %s ", sdk.Stringify(Data), project.Name, project.RefID)
+	err = qc.GraphRequester.QueryOperation(issuesQuery, variables, &Data)
+	if err != nil {
 		return
 	}
 
 	sdk.LogDebug(qc.Logger, "issues found", "len", Data.Project.Issues.Count)
 
 	for _, rawissue := range Data.Project.Issues.Edges {
+		if stop.Reached(rawissue.Node.UpdatedAt) {
+			break
+		}
 		issue, err := rawissue.Node.ToModel(qc, project)
 		if err != nil {
-			return nextPage, err
+			return pageInfo, err
 		}
 		issues <- issue
 	}
 
-	if !Data.Project.Issues.PageInfo.HasNextPage {
-		return
-	}
-
-	nextPage = NextPage(Data.Project.Issues.PageInfo.EndCursor)
-
-	return
+	return Data.Project.Issues.PageInfo, nil
 }
 
 func getIssueTypeFromLabels(tags []string, qc QueryContext) (string, string) {
@@ -609,20 +602,15 @@ func convertMutationToGitlabIssue(m *sdk.WorkIssueCreateMutation) IssueCreateMod
 	}
 }
 
-const updateIssueIterationQuery = `mutation {
-	issueSetIteration(input:{
-	  clientMutationId:"%s",
-	  projectPath:"%s",
-	  iid:"%s",
-	  iterationId:"gid://gitlab/Iteration/%s"
-	}) {
+var updateIssueIterationQuery = newGraphqlOperation("IssueSetIteration", `mutation IssueSetIteration($input: IssueSetIterationInput!) {
+	issueSetIteration(input:$input) {
 	  errors
 	  clientMutationId
 	  issue{
 		id
 	  }
 	}
-  }`
+  }`)
 
 type issueUpdateResponse struct {
 	IssueSetIteration struct {
@@ -646,9 +634,16 @@ func updateIssueIteration(qc QueryContext, mutationID string, issueRefID string)
 
 	projectDetails := qc.WorkManager.GetProjectDetails(projectID)
 
-	query := fmt.Sprintf(updateIssueIterationQuery, mutationID, projectDetails.ProjectPath, issueD.IID, mutationID)
+	variables := graphqlVariables{
+		"input": map[string]interface{}{
+			"clientMutationId": mutationID,
+			"projectPath":      projectDetails.ProjectPath,
+			"iid":              issueD.IID,
+			"iterationId":      "gid://gitlab/Iteration/" + mutationID,
+		},
+	}
 
-	err := qc.GraphRequester.Query(query, nil, &response)
+	err := qc.GraphRequester.QueryOperation(updateIssueIterationQuery, variables, &response)
 	if err != nil {
 		return err
 	}
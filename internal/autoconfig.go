@@ -12,7 +12,7 @@ func (g *GitlabIntegration) AutoConfigure(autoconfig sdk.AutoConfigure) (*sdk.Co
 
 	sdk.LogInfo(logger, "auto-configure started")
 
-	ge, err := g.SetQueryConfig(logger, config, g.manager, autoconfig.CustomerID())
+	ge, err := g.SetQueryConfig(g.context(), logger, config, g.manager, autoconfig.CustomerID())
 	if err != nil {
 		return nil, err
 	}
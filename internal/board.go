@@ -13,7 +13,7 @@ func (ge *GitlabExport) exportGroupBoards(namespace *api.Namespace, repos []*api
 		return nil
 	}
 
-	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (pi api.NextPage, rerr error) {
+	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (pi api.NextPage, rerr error) {
 		pi, err := api.GroupBoardsPage(ge.qc, namespace, repos, params)
 		if err != nil {
 			return pi, err
@@ -27,7 +27,7 @@ func (ge *GitlabExport) exportReposBoards(repos []*api.GitlabProjectInternal) er
 	sdk.LogInfo(ge.qc.Logger, "exporting repo boards", "repos", repos)
 
 	for _, repo := range repos {
-		err := api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (pi api.NextPage, rerr error) {
+		err := api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (pi api.NextPage, rerr error) {
 			pi, err := api.RepoBoardsPage(ge.qc, repo, params)
 			if err != nil {
 				return pi, err
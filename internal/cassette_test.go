@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/gitlab/internal/gitlabtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCassetteExportHistorical replays the responses recorded from a real gitlab, record the cassette
+// with -record to find the model conversions broken by a change of the payloads
+func TestCassetteExportHistorical(t *testing.T) {
+	assert := assert.New(t)
+	instance, manager := gitlabtest.UseCassette(t, filepath.Join("testdata", "cassettes", "export_historical.json"))
+	g := &GitlabIntegration{}
+	assert.NoError(g.Start(instance.Logger, instance.Config, manager))
+	assert.NoError(g.Export(instance.Export(true)))
+	gitlabtest.AssertGolden(t, filepath.Join("testdata", "golden", "cassette_export_historical.json"), gitlabtest.Records(instance.Pipe.Written()))
+}
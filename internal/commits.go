@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+)
+
+const commitsHeadKeyPrefix = "repo_commits_head_"
+
+// commitsHeadExpiry is how long the head is kept without an export, the whole branch is exported
+// again once it expires
+const commitsHeadExpiry = 30 * 24 * time.Hour
+
+// commitsHeadKey is the state key of the head of the default branch exported last for the repo
+func commitsHeadKey(repoRefID string) string {
+	return commitsHeadKeyPrefix + repoRefID
+}
+
+// exportRepoCommits exports the history of the default branch. Incrementals continue from the head
+// exported last, the commits reachable from the branch and not from that head are exported whatever
+// their dates are, so the commits of rebased and merged old branches aren't missed
+func (ge *GitlabExport) exportRepoCommits(repo *api.GitlabProjectInternal) error {
+
+	logger := sdk.LogWith(ge.logger, "repo", repo.Name, "repo_ref_id", repo.RefID)
+
+	if repo.DefaultBranch == "" {
+		sdk.LogDebug(logger, "skipping commits, the repo is empty")
+		return nil
+	}
+
+	head, err := api.RepoBranchHead(ge.qc, repo, repo.DefaultBranch)
+	// the repository is forbidden or missing when it's disabled in the project
+	if errors.Is(err, api.ErrForbidden) || errors.Is(err, api.ErrNotFound) {
+		sdk.LogDebug(logger, "skipping commits, the repository is disabled", "err", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	key := commitsHeadKey(repo.RefID)
+
+	var last string
+	if !ge.historical {
+		if _, err := ge.state.Get(key, &last); err != nil {
+			return err
+		}
+	}
+	if last == head {
+		sdk.LogDebug(logger, "skipping commits, the branch didn't change", "branch", repo.DefaultBranch, "head", head)
+		return nil
+	}
+
+	ref := repo.DefaultBranch
+	if last != "" {
+		ref = last + ".." + repo.DefaultBranch
+	}
+
+	sdk.LogDebug(logger, "exporting commits", "branch", repo.DefaultBranch, "ref", ref)
+
+	err = ge.exportRefCommits(logger, repo, ref)
+	// the head exported last is gone when the branch was force pushed
+	if errors.Is(err, api.ErrNotFound) && ref != repo.DefaultBranch {
+		sdk.LogWarn(logger, "head exported last not found, exporting the whole branch", "head", last)
+		err = ge.exportRefCommits(logger, repo, repo.DefaultBranch)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ge.state.SetWithExpires(key, head, commitsHeadExpiry)
+}
+
+func (ge *GitlabExport) exportRefCommits(logger sdk.Logger, repo *api.GitlabProjectInternal, ref string) error {
+	return api.Paginate(logger, "", time.Time{}, func(log sdk.Logger, params url.Values, stop *api.PageStop) (api.NextPage, error) {
+		pi, commits, err := api.RepoCommitsPage(ge.qc, repo, ref, params)
+		if err != nil {
+			return pi, err
+		}
+		for _, c := range commits {
+			c.IntegrationInstanceID = ge.integrationInstanceID
+			if err := ge.pipe.Write(c); err != nil {
+				return pi, err
+			}
+		}
+		return pi, nil
+	})
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+)
+
+// repoEnvironments environment name to environment
+type repoEnvironments map[string]*api.Environment
+
+// repoEnvironments returns the environments of the repo
+func (ge *GitlabExport) repoEnvironments(repo *api.GitlabProjectInternal) (repoEnvironments, error) {
+
+	logger := sdk.LogWith(ge.logger, "repo", repo.Name, "repo_ref_id", repo.RefID)
+
+	environments := make(repoEnvironments)
+
+	err := api.Paginate(logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (api.NextPage, error) {
+		pi, arr, err := api.EnvironmentsPage(ge.qc, repo, params)
+		if err != nil {
+			return pi, err
+		}
+		for _, env := range arr {
+			environments[env.Name] = env
+		}
+		return pi, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return environments, nil
+}
+
+// exportRepoDeployments exports the deployments of the repo, incrementals only the ones updated since the last export
+func (ge *GitlabExport) exportRepoDeployments(repo *api.GitlabProjectInternal) error {
+
+	logger := sdk.LogWith(ge.logger, "repo", repo.Name, "repo_ref_id", repo.RefID)
+
+	environments, err := ge.repoEnvironments(repo)
+	// the environments are forbidden when deployments are disabled in the repo
+	if errors.Is(err, api.ErrForbidden) {
+		sdk.LogDebug(logger, "skipping deployments, they are disabled")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return api.Paginate(logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (api.NextPage, error) {
+		if ge.lastExportDateGitlabFormat != "" {
+			// updated_after only works when ordering by updated_at
+			params.Set("order_by", "updated_at")
+			params.Set("updated_after", ge.lastExportDateGitlabFormat)
+		}
+		pi, deployments, err := api.DeploymentsPage(ge.qc, repo, params)
+		if err != nil {
+			return pi, err
+		}
+		for _, d := range deployments {
+			if err := ge.writeDeployment(repo, d, environments); err != nil {
+				return pi, err
+			}
+		}
+		return pi, nil
+	})
+}
+
+// exportDeployment refetches the deployment and writes it, environment is the environment of the webhook
+// for the deployments without tier
+func (ge *GitlabExport) exportDeployment(repo *api.GitlabProjectInternal, deploymentID int64, environment *api.Environment) error {
+
+	deployment, err := api.DeploymentByID(ge.qc, repo, deploymentID)
+	if err != nil {
+		return err
+	}
+
+	return ge.writeDeployment(repo, deployment, repoEnvironments{environment.Name: environment})
+}
+
+// writeDeployment writes the deployment, the tier is taken from the environments when the deployment doesn't have it
+func (ge *GitlabExport) writeDeployment(repo *api.GitlabProjectInternal, d *api.Deployment, environments repoEnvironments) error {
+
+	var tier string
+	if env := environments[d.Environment.Name]; env != nil {
+		tier = env.Tier
+	}
+
+	deployment := d.ToCICDDeployment(ge.qc.CustomerID, gitlabRefType, repo.FullPath, tier)
+	deployment.IntegrationInstanceID = ge.integrationInstanceID
+	return ge.pipe.Write(deployment)
+}
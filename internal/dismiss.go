@@ -19,7 +19,7 @@ func (g *GitlabIntegration) Dismiss(instance sdk.Instance) error {
 
 	sdk.LogInfo(logger, "dismiss started")
 
-	ge, err := g.SetQueryConfig(logger, config, g.manager, instance.CustomerID())
+	ge, err := g.SetQueryConfig(g.context(), logger, config, g.manager, instance.CustomerID())
 	if err != nil {
 		return fmt.Errorf("error creating http client: %w", err)
 	}
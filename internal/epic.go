@@ -2,7 +2,6 @@ package internal
 
 import (
 	"net/url"
-	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/gitlab/internal/api"
@@ -21,7 +20,7 @@ func (ge *GitlabExport) exportEpics(namespace *api.Namespace, repos []*api.Gitla
 		}
 	}
 
-	return api.Paginate(ge.logger, "", ge.lastExportDate, func(log sdk.Logger, params url.Values, _ time.Time) (api.NextPage, error) {
+	return api.Paginate(ge.logger, "", ge.lastExportDate, func(log sdk.Logger, params url.Values, _ *api.PageStop) (api.NextPage, error) {
 		if ge.lastExportDateGitlabFormat != "" {
 			params.Set("updated_after", ge.lastExportDateGitlabFormat)
 		}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"time"
@@ -23,11 +24,12 @@ type GitlabExport struct {
 	lastExportKey              string
 	systemWebHooksEnabled      bool
 	repoProjectManager         *RepoProjectManager
+	workManagerRestored        bool
 }
 
 const concurrentAPICalls = 10
 
-func (i *GitlabIntegration) SetQueryConfig(logger sdk.Logger, config sdk.Config, manager sdk.Manager, customerID string) (ge GitlabExport, rerr error) {
+func (i *GitlabIntegration) SetQueryConfig(ctx context.Context, logger sdk.Logger, config sdk.Config, manager sdk.Manager, customerID string) (ge GitlabExport, rerr error) {
 
 	apiURL, client, graphql, err := newHTTPClient(logger, config, manager)
 	if err != nil {
@@ -35,16 +37,19 @@ func (i *GitlabIntegration) SetQueryConfig(logger sdk.Logger, config sdk.Config,
 		return
 	}
 
-	r := api.NewRequester(logger, client, concurrentAPICalls)
+	rateLimiter := api.NewRateLimiter(logger)
+	retryPolicy := api.DefaultRetryPolicy()
+	r := api.NewRequester(ctx, logger, client, rateLimiter, retryPolicy, concurrentAPICalls)
 	ge.qc.Get = r.Get
 	ge.qc.Post = r.Post
 	ge.qc.Delete = r.Delete
 	ge.qc.Put = r.Put
+	ge.qc.Context = ctx
 	ge.qc.Logger = logger
 	ge.qc.RefType = gitlabRefType
 	ge.qc.CustomerID = customerID
 	ge.qc.RefType = gitlabRefType
-	ge.qc.GraphRequester = api.NewGraphqlRequester(graphql, concurrentAPICalls, logger)
+	ge.qc.GraphRequester = api.NewGraphqlRequester(ctx, graphql, rateLimiter, retryPolicy, concurrentAPICalls, logger)
 	ge.logger = logger
 
 	u, err := url.Parse(apiURL)
@@ -59,12 +64,12 @@ func (i *GitlabIntegration) SetQueryConfig(logger sdk.Logger, config sdk.Config,
 	return ge, nil
 }
 
-func gitlabExport(i *GitlabIntegration, logger sdk.Logger, export sdk.Export) (ge GitlabExport, rerr error) {
+func gitlabExport(ctx context.Context, i *GitlabIntegration, logger sdk.Logger, export sdk.Export) (ge GitlabExport, rerr error) {
 
 	// TODO: Add logic for incrementals
 	// to get users and repos details
 	// if there is not system hook available
-	ge, rerr = i.SetQueryConfig(logger, export.Config(), i.manager, export.CustomerID())
+	ge, rerr = i.SetQueryConfig(ctx, logger, export.Config(), i.manager, export.CustomerID())
 	if rerr != nil {
 		return
 	}
@@ -131,7 +136,11 @@ func (i *GitlabIntegration) Export(export sdk.Export) error {
 
 	config := export.Config()
 
-	gexport, err := gitlabExport(i, logger, export)
+	// cancel the outstanding requests when the export finishes, even if it fails
+	ctx, cancel := context.WithCancel(i.context())
+	defer cancel()
+
+	gexport, err := gitlabExport(ctx, i, logger, export)
 	if err != nil {
 		return err
 	}
@@ -168,6 +177,10 @@ func (i *GitlabIntegration) Export(export sdk.Export) error {
 
 	sdk.LogInfo(logger, "registering webhooks done")
 
+	if err := setExportedNamespaces(gexport.state, allnamespaces); err != nil {
+		return err
+	}
+
 	if gexport.historical {
 		sdk.LogInfo(logger, "deleting work manager state")
 		if err := gexport.qc.WorkManager.Delete(); err != nil {
@@ -176,10 +189,14 @@ func (i *GitlabIntegration) Export(export sdk.Export) error {
 		}
 	} else {
 		sdk.LogInfo(logger, "recovering work manager state")
+		gexport.workManagerRestored = gexport.state.Exists(workManagerKey)
 		if err := gexport.qc.WorkManager.Restore(); err != nil {
 			sdk.LogError(logger, "error recovering work manager state", "err", err)
 			return err
 		}
+		if !gexport.workManagerRestored {
+			sdk.LogWarn(logger, "work manager state not found, all the issues will be exported")
+		}
 	}
 
 	validServerVersion := true
@@ -197,6 +214,10 @@ func (i *GitlabIntegration) Export(export sdk.Export) error {
 
 	for _, namespace := range allnamespaces {
 		l := sdk.LogWith(logger, "namespace_id", namespace.ID, "namespace_name", namespace.Name)
+		if err := gexport.exportNamespaceMembers(namespace); err != nil {
+			sdk.LogError(l, "error exporting namespace members", "err", err)
+			return err
+		}
 		projectUsersMap := make(map[string]api.UsernameMap)
 		repos, err := gexport.exportNamespaceSourceCode(namespace, projectUsersMap)
 		if err != nil {
@@ -322,6 +343,15 @@ func (ge *GitlabExport) exportRepoAndWrite(repo *api.GitlabProjectInternal, proj
 	}
 	ge.repoProjectManager.AddRepo(repo)
 	ge.exportRepoPullRequests(repo)
+	if err := ge.exportRepoCommits(repo); err != nil {
+		return err
+	}
+	if err := ge.exportRepoPipelines(repo); err != nil {
+		return err
+	}
+	if err := ge.exportRepoDeployments(repo); err != nil {
+		return err
+	}
 	if ge.isGitlabCloud {
 		users, err := ge.exportRepoUsers(repo)
 		if err != nil {
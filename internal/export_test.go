@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/gitlabtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// tolerated are the fixtures left out of testdata/gitlab on purpose, they are empty collections
+var tolerated = []string{
+	"GET groups/10/boards",
+	"GET groups/10/epics",
+	"GET groups/10/hooks",
+	"GET groups/10/milestones",
+	"GET projects/100/boards",
+	"GET projects/100/issues/1/links",
+	"GET projects/100/issues/1/resource_state_events",
+	"GET projects/100/issues/2/discussions.json",
+	"GET projects/100/issues/2/links",
+	"GET projects/100/issues/2/resource_state_events",
+	"GRAPHQL IssueDesigns",
+	"GRAPHQL IssueDiscussions",
+}
+
+func newTestIntegration(t *testing.T) (*GitlabIntegration, *gitlabtest.Server, *gitlabtest.Manager, *gitlabtest.Instance) {
+	server := gitlabtest.NewServer(filepath.Join("testdata", "gitlab"))
+	t.Cleanup(server.Close)
+	manager := gitlabtest.NewManager()
+	instance := gitlabtest.NewInstance(server.URL)
+	g := &GitlabIntegration{}
+	assert.NoError(t, g.Start(instance.Logger, instance.Config, manager))
+	return g, server, manager, instance
+}
+
+func assertNoMissingFixtures(t *testing.T, server *gitlabtest.Server) {
+	t.Helper()
+	for _, m := range server.Missing() {
+		assert.Contains(t, tolerated, m, "missing fixture")
+	}
+}
+
+// webhookTokenHeader returns the token header of the hooks registered by an export
+func webhookTokenHeader() map[string]string {
+	return map[string]string{"x-gitlab-token": gitlabtest.WebHookSecret}
+}
+
+func TestExportHistorical(t *testing.T) {
+	assert := assert.New(t)
+	g, server, manager, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	assertNoMissingFixtures(t, server)
+	assert.NotEmpty(manager.WebHooks.Hooks())
+	assert.True(instance.State.Exists("last_export_date"))
+	gitlabtest.AssertGolden(t, filepath.Join("testdata", "golden", "export_historical.json"), gitlabtest.Records(instance.Pipe.Written()))
+}
+
+func TestExportCommitsForbidden(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	server.Respond("GET", "projects/100/repository/branches/main", 403, `{"message": "403 Forbidden"}`)
+	assert.NoError(g.Export(instance.Export(true)))
+	// the steps after the commits still run
+	var deployments int
+	for _, m := range instance.Pipe.Written() {
+		if _, ok := m.(*sdk.CICDDeployment); ok {
+			deployments++
+		}
+	}
+	assert.NotZero(deployments)
+	assert.False(instance.State.Exists(commitsHeadKey("100")))
+}
+
+func TestExportDeployments(t *testing.T) {
+	assert := assert.New(t)
+	g, _, _, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	deployments := make(map[string]*sdk.CICDDeployment)
+	// sha to pull request ids, the deployments are linked to the pull requests through their commits
+	pullRequests := make(map[string][]string)
+	for _, m := range instance.Pipe.Written() {
+		switch model := m.(type) {
+		case *sdk.CICDDeployment:
+			deployments[model.RefID] = model
+		case *sdk.SourceCodePullRequestCommit:
+			pullRequests[model.Sha] = append(pullRequests[model.Sha], model.PullRequestID)
+		}
+	}
+	if deployment, ok := deployments["800"]; assert.True(ok) {
+		assert.Equal(sdk.CICDDeploymentStatusPass, deployment.Status)
+		assert.Equal(sdk.CICDDeploymentEnvironmentProduction, deployment.Environment)
+		assert.Equal("a1b2c3d4e5f60718293a4b5c6d7e8f9012345678", deployment.CommitSha)
+		assert.Equal("2020-11-10T08:20:00+00:00", deployment.EndDate.Rfc3339)
+		repoID := sdk.NewSourceCodeRepoID(gitlabtest.CustomerID, "100", gitlabRefType)
+		assert.Equal([]string{sdk.NewSourceCodePullRequestID(gitlabtest.CustomerID, "2000", gitlabRefType, repoID)}, pullRequests[deployment.CommitSha])
+	}
+	if deployment, ok := deployments["801"]; assert.True(ok) {
+		assert.Equal(sdk.CICDDeploymentStatusFail, deployment.Status)
+		// the tier comes from the environments when the deployment doesn't have it
+		assert.Equal(sdk.CICDDeploymentEnvironmentStaging, deployment.Environment)
+	}
+}
+
+func TestExportIncremental(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	// the historical export persists the work manager state the incremental export relies on
+	assert.NoError(g.Export(instance.Export(true)))
+	instance.Pipe.Reset()
+	assert.NoError(instance.State.Set("last_export_date", "2020-11-15T00:00:00Z"))
+	assert.NoError(instance.State.Set(commitsHeadKey("100"), "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"))
+	historicalRequests := len(server.Requests())
+	assert.NoError(g.Export(instance.Export(false)))
+	assertNoMissingFixtures(t, server)
+	for _, r := range server.Requests()[historicalRequests:] {
+		if r.Path == "projects/100/merge_requests" && r.Query.Get("updated_after") != "" {
+			assert.Equal("2020-11-15T00:00:00.000Z", r.Query.Get("updated_after"))
+		}
+		if r.Path == "projects/100/repository/commits" {
+			assert.Equal("a1b2c3d4e5f60718293a4b5c6d7e8f9012345678..main", r.Query.Get("ref_name"))
+		}
+	}
+	var issues, sprints, commits int
+	var deployments []string
+	for _, m := range instance.Pipe.Written() {
+		switch model := m.(type) {
+		case *sdk.CICDDeployment:
+			deployments = append(deployments, model.RefID)
+		case *sdk.SourceCodeCommit:
+			commits++
+			assert.Equal("d4e5f60718293a4b5c6d7e8f9012345678a1b2c3", model.Sha)
+			assert.Equal(int64(12), model.Additions)
+			assert.Equal(int64(3), model.Deletions)
+		case *sdk.WorkIssue:
+			issues++
+			assert.Equal("5001", model.RefID)
+		case *sdk.AgileSprint:
+			sprints++
+			// issue 5000 wasn't updated but the sprint still has it
+			assert.Contains(model.IssueIds, sdk.NewWorkIssueID(gitlabtest.CustomerID, "5000", gitlabRefType))
+		}
+	}
+	assert.Equal(1, issues)
+	assert.Equal(1, sprints)
+	assert.Equal(1, commits)
+	assert.Equal([]string{"801"}, deployments)
+	gitlabtest.AssertGolden(t, filepath.Join("testdata", "golden", "export_incremental.json"), gitlabtest.Records(instance.Pipe.Written()))
+}
+
+func TestExportPullRequestReviews(t *testing.T) {
+	assert := assert.New(t)
+	g, _, _, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	repoID := sdk.NewSourceCodeRepoID(gitlabtest.CustomerID, "100", gitlabRefType)
+	pullRequestID := sdk.NewSourceCodePullRequestID(gitlabtest.CustomerID, "2000", gitlabRefType, repoID)
+	var reviews []string
+	requests := make(map[string]bool)
+	for _, m := range instance.Pipe.Written() {
+		switch model := m.(type) {
+		case *sdk.SourceCodePullRequestReview:
+			if model.PullRequestID == pullRequestID {
+				assert.Equal("2", model.UserRefID)
+				reviews = append(reviews, model.RefID+" "+string(model.State)+" "+sdk.DateFromEpoch(model.CreatedDate.Epoch).UTC().Format(time.RFC3339))
+			}
+		case *sdk.SourceCodePullRequestReviewRequest:
+			requests[model.RefID] = model.Active
+		}
+	}
+	// each approval and unapproval is a review with the time of its system note
+	assert.Equal([]string{
+		"2998 " + string(sdk.SourceCodePullRequestReviewStateApproved) + " 2020-10-02T09:00:00Z",
+		"2999 " + string(sdk.SourceCodePullRequestReviewStateDismissed) + " 2020-10-02T09:30:00Z",
+		"3001 " + string(sdk.SourceCodePullRequestReviewStateApproved) + " 2020-10-05T11:00:00Z",
+	}, reviews)
+	// the reviewer of the merged pull request approved it, the other one is still requested along
+	// with the code owners of the unapproved rule
+	assert.Equal(map[string]bool{"2000-request-2": false, "2001-request-1": true, "2001-request-2": true}, requests)
+}
+
+func TestMutationUpdateIssue(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	// the export persists the work manager state the mutation needs to find the issue
+	assert.NoError(g.Export(instance.Export(true)))
+	title := "Widget no longer spins out of control"
+	payload := &sdk.WorkIssueUpdateMutation{}
+	payload.Set.Title = &title
+	res, err := g.Mutation(instance.Mutation("5001", "5001", "work.Issue", sdk.UpdateAction, payload))
+	assert.NoError(err)
+	if assert.NotNil(res) {
+		assert.Equal("5001", *res.RefID)
+	}
+	var put *gitlabtest.Request
+	for _, r := range server.Requests() {
+		if r.Method == "PUT" {
+			r := r
+			put = &r
+		}
+	}
+	if assert.NotNil(put) {
+		assert.Equal("projects/100/issues/1", put.Path)
+		assert.Equal(title, put.Query.Get("title"))
+	}
+}
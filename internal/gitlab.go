@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"sync"
@@ -14,6 +15,8 @@ type GitlabIntegration struct {
 	config  sdk.Config
 	manager sdk.Manager
 	lock    sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
 }
 
 var _ sdk.Integration = (*GitlabIntegration)(nil)
@@ -22,10 +25,19 @@ var _ sdk.Integration = (*GitlabIntegration)(nil)
 func (g *GitlabIntegration) Start(logger sdk.Logger, config sdk.Config, manager sdk.Manager) error {
 	g.config = config
 	g.manager = manager
+	g.ctx, g.cancel = context.WithCancel(context.Background())
 	sdk.LogInfo(logger, "starting")
 	return nil
 }
 
+// context returns the context which is cancelled when the integration stops
+func (g *GitlabIntegration) context() context.Context {
+	if g.ctx == nil {
+		return context.Background()
+	}
+	return g.ctx
+}
+
 const (
 	// FetchAccounts will fetch accounts
 	FetchAccounts = "FETCH_ACCOUNTS"
@@ -44,7 +56,7 @@ func (g *GitlabIntegration) Validate(validate sdk.Validate) (map[string]interfac
 	switch action {
 	case FetchAccounts:
 
-		ge, err := g.SetQueryConfig(logger, config, g.manager, validate.CustomerID())
+		ge, err := g.SetQueryConfig(g.context(), logger, config, g.manager, validate.CustomerID())
 		if err != nil {
 			return nil, err
 		}
@@ -104,11 +116,14 @@ func (g *GitlabIntegration) Enroll(instance sdk.Instance) error {
 // Stop is called when the integration is shutting down for cleanup
 func (g *GitlabIntegration) Stop(logger sdk.Logger) error {
 	sdk.LogInfo(logger, "stopping")
-	// TODO: Add Stop functionality
+	// cancel all the outstanding api requests
+	if g.cancel != nil {
+		g.cancel()
+	}
 	return nil
 }
 
-func newHTTPClient(logger sdk.Logger, config sdk.Config, manager sdk.Manager) (url string, cl sdk.HTTPClient, cl2 sdk.GraphQLClient, err error) {
+func newHTTPClient(logger sdk.Logger, config sdk.Config, manager sdk.Manager) (url string, cl sdk.HTTPClient, cl2 sdk.HTTPClient, err error) {
 
 	url = "https://gitlab.com/api/v4/"
 	graphqlurl := "https://gitlab.com/api/graphql/"
@@ -123,7 +138,7 @@ func newHTTPClient(logger sdk.Logger, config sdk.Config, manager sdk.Manager) (u
 			"Authorization": "bearer " + apikey,
 		}
 		cl = manager.HTTPManager().New(url, headers)
-		cl2 = manager.GraphQLManager().New(graphqlurl, headers)
+		cl2 = manager.HTTPManager().New(graphqlurl, headers)
 		sdk.LogInfo(logger, "using apikey authorization", "apikey", apikey, "url", url)
 	} else if config.OAuth2Auth != nil {
 		authToken := config.OAuth2Auth.AccessToken
@@ -135,7 +150,7 @@ func newHTTPClient(logger sdk.Logger, config sdk.Config, manager sdk.Manager) (u
 			"Authorization": "bearer " + authToken,
 		}
 		cl = manager.HTTPManager().New(url, headers)
-		cl2 = manager.GraphQLManager().New(graphqlurl, headers)
+		cl2 = manager.HTTPManager().New(graphqlurl, headers)
 		sdk.LogInfo(logger, "using oauth2 authorization")
 	} else if config.BasicAuth != nil {
 		// TODO: check if this type is supported by gitlab
@@ -147,7 +162,7 @@ func newHTTPClient(logger sdk.Logger, config sdk.Config, manager sdk.Manager) (u
 			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(config.BasicAuth.Username+":"+config.BasicAuth.Password)),
 		}
 		cl = manager.HTTPManager().New(url, headers)
-		cl2 = manager.GraphQLManager().New(graphqlurl, headers)
+		cl2 = manager.HTTPManager().New(graphqlurl, headers)
 		sdk.LogInfo(logger, "using basic authorization", "username", config.BasicAuth.Username)
 	} else {
 		err = fmt.Errorf("supported authorization not provided. support for: apikey, oauth2, basic")
@@ -0,0 +1,329 @@
+package gitlabtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var record = flag.Bool("record", false, "record the cassettes against the gitlab at GITLAB_URL using the token in GITLAB_TOKEN")
+
+// Redacted replaces the secrets found in the cassettes
+const Redacted = "REDACTED"
+
+// responseHeaders are the response headers saved in the cassettes, the integration doesn't use the others
+var responseHeaders = []string{"Content-Type", "Link", "X-Next-Page", "X-Page", "X-Per-Page", "X-Total", "X-Total-Pages"}
+
+// secretFields are json fields holding secrets in gitlab payloads, like the hooks token
+var secretFields = regexp.MustCompile(`"(token|private_token|access_token|secret|password)"(\s*):(\s*)"[^"]*"`)
+
+// secretParams are query params holding secrets
+var secretParams = []string{"private_token", "access_token", "token"}
+
+// Interaction is a request sent to gitlab and the response received
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is a recorded request, graphql requests are identified by operation and variables
+// instead of body so editing a query doesn't invalidate the cassettes
+type CassetteRequest struct {
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	Query     string          `json:"query,omitempty"`
+	Body      string          `json:"body,omitempty"`
+	Operation string          `json:"operation,omitempty"`
+	Mutation  bool            `json:"mutation,omitempty"`
+	Variables json.RawMessage `json:"variables,omitempty"`
+}
+
+func (r CassetteRequest) key() string {
+	if r.Operation != "" {
+		// the saved cassettes are indented
+		var variables bytes.Buffer
+		json.Compact(&variables, r.Variables)
+		return strings.Join([]string{r.Method, r.Path, r.Operation, variables.String()}, " ")
+	}
+	return strings.Join([]string{r.Method, r.Path, r.Query, r.Body}, " ")
+}
+
+// CassetteResponse is a recorded response, json bodies are kept as json to make the cassettes readable
+type CassetteResponse struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	JSON       json.RawMessage   `json:"json,omitempty"`
+	Text       string            `json:"text,omitempty"`
+}
+
+// Cassette records the interactions with gitlab to replay them in tests without network access.
+//
+// A cassette is an http.RoundTripper, set it as the transport of the http client manager to replay
+// it, the interactions are matched by method, path, query and body, or by operation and variables
+// for graphql. Graphql mutations fall back to match by operation, their variables usually hold
+// the time of the export. Identical requests are answered in the order they were recorded, repeating the last
+// response once exhausted. Requests not found in the cassette are answered with 404.
+type Cassette struct {
+	// URL is the gitlab url the cassette was recorded against
+	URL          string        `json:"url"`
+	Interactions []Interaction `json:"interactions"`
+
+	mu      sync.Mutex
+	secrets []string
+	played  map[string]int
+	missing []string
+}
+
+// NewCassette returns an empty cassette to record the interactions with the gitlab at theurl, the
+// secrets are redacted from the recorded requests and responses
+func NewCassette(theurl string, secrets ...string) *Cassette {
+	return &Cassette{URL: theurl, secrets: secrets, played: make(map[string]int)}
+}
+
+// LoadCassette loads a cassette from file
+func LoadCassette(file string) (*Cassette, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c := NewCassette("")
+	if err := json.Unmarshal(buf, c); err != nil {
+		return nil, fmt.Errorf("error decoding cassette %s: %w", file, err)
+	}
+	return c, nil
+}
+
+// Save writes the cassette to file
+func (c *Cassette) Save(file string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	buf, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(buf, '\n'), 0644)
+}
+
+// Missing returns the requests not found in the cassette, sorted
+func (c *Cassette) Missing() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	missing := append([]string(nil), c.missing...)
+	sort.Strings(missing)
+	return missing
+}
+
+// RoundTrip answers the request with the recorded response
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	r, err := c.cassetteRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	key := r.key()
+	c.mu.Lock()
+	var matches []CassetteResponse
+	for _, i := range c.Interactions {
+		if i.Request.key() == key {
+			matches = append(matches, i.Response)
+		}
+	}
+	if len(matches) == 0 && r.Mutation {
+		for _, i := range c.Interactions {
+			if i.Request.Mutation && i.Request.Operation == r.Operation && i.Request.Path == r.Path {
+				matches = append(matches, i.Response)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		c.missing = append(c.missing, key)
+		c.mu.Unlock()
+		return newResponse(req, CassetteResponse{
+			StatusCode: http.StatusNotFound,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			JSON:       json.RawMessage(`{"message":"404 Not found in cassette"}`),
+		}), nil
+	}
+	n := c.played[key]
+	c.played[key]++
+	c.mu.Unlock()
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	return newResponse(req, matches[n]), nil
+}
+
+func newResponse(req *http.Request, r CassetteResponse) *http.Response {
+	body := []byte(r.Text)
+	if len(r.JSON) > 0 {
+		body = r.JSON
+	}
+	header := http.Header{}
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// Recorder returns a transport sending the requests with next and recording the interactions
+func (c *Cassette) Recorder(next http.RoundTripper) http.RoundTripper {
+	return &recorder{cassette: c, next: next}
+}
+
+type recorder struct {
+	cassette *Cassette
+	next     http.RoundTripper
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	creq, err := r.cassette.cassetteRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	cresp := CassetteResponse{StatusCode: resp.StatusCode, Headers: make(map[string]string)}
+	for _, k := range responseHeaders {
+		if v := resp.Header.Get(k); v != "" {
+			cresp.Headers[k] = r.cassette.scrub(v)
+		}
+	}
+	scrubbed := r.cassette.scrub(string(body))
+	if json.Valid([]byte(scrubbed)) {
+		cresp.JSON = json.RawMessage(scrubbed)
+	} else {
+		cresp.Text = scrubbed
+	}
+
+	r.cassette.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{Request: creq, Response: cresp})
+	r.cassette.mu.Unlock()
+	return resp, nil
+}
+
+// cassetteRequest returns the request as recorded, it reads the body and restores it
+func (c *Cassette) cassetteRequest(req *http.Request) (CassetteRequest, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return CassetteRequest{}, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	query := req.URL.Query()
+	for _, p := range secretParams {
+		if query.Get(p) != "" {
+			query.Set(p, Redacted)
+		}
+	}
+	r := CassetteRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  c.scrub(query.Encode()),
+	}
+	if strings.HasSuffix(req.URL.Path, GraphqlPath) {
+		var payload struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.Unmarshal(body, &payload); err == nil {
+			r.Operation = "anonymous"
+			if match := graphqlOperationName.FindStringSubmatch(payload.Query); match != nil {
+				r.Operation = match[2]
+				r.Mutation = match[1] == "mutation"
+			}
+			if len(payload.Variables) > 0 {
+				// encoding/json sorts the keys so the variables can be compared as strings
+				buf, _ := json.Marshal(payload.Variables)
+				r.Variables = json.RawMessage(c.scrub(string(buf)))
+			}
+			return r, nil
+		}
+	}
+	r.Body = c.scrub(string(body))
+	return r, nil
+}
+
+// scrub redacts the secrets from s
+func (c *Cassette) scrub(s string) string {
+	for _, secret := range c.secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, Redacted)
+			s = strings.ReplaceAll(s, url.QueryEscape(secret), Redacted)
+		}
+	}
+	return secretFields.ReplaceAllString(s, `"$1"$2:$3"`+Redacted+`"`)
+}
+
+// UseCassette returns an instance and a manager talking to gitlab through the cassette in file.
+//
+// When the tests run with -record the interactions with the gitlab at GITLAB_URL are recorded using
+// the token in GITLAB_TOKEN and the cassette is saved when the test finishes, the test is skipped
+// if they aren't set. Otherwise the cassette is replayed and the test fails if a request isn't found,
+// the test is skipped until the cassette is recorded.
+func UseCassette(t *testing.T, file string) (*Instance, *Manager) {
+	t.Helper()
+	manager := NewManager()
+	if *record {
+		theurl, token := os.Getenv("GITLAB_URL"), os.Getenv("GITLAB_TOKEN")
+		if theurl == "" || token == "" {
+			t.Skip("GITLAB_URL and GITLAB_TOKEN are required to record cassettes")
+		}
+		cassette := NewCassette(theurl, token)
+		manager.HTTP.Transport = cassette.Recorder(http.DefaultTransport)
+		t.Cleanup(func() {
+			if err := cassette.Save(file); err != nil {
+				t.Errorf("error saving cassette: %s", err)
+			}
+		})
+		return newInstance(theurl, token), manager
+	}
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		t.Skipf("cassette %s not recorded yet, record it with -record", file)
+	}
+	cassette, err := LoadCassette(file)
+	if err != nil {
+		t.Fatalf("error loading cassette: %s", err)
+	}
+	manager.HTTP.Transport = cassette
+	t.Cleanup(func() {
+		for _, m := range cassette.Missing() {
+			t.Errorf("request not found in cassette %s: %s", file, m)
+		}
+	})
+	return newInstance(cassette.URL, Redacted), manager
+}
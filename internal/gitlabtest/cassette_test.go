@@ -0,0 +1,56 @@
+package gitlabtest
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	assert := assert.New(t)
+	server := NewServer(t.TempDir())
+	defer server.Close()
+	server.Respond("GET", "projects/100/hooks", 200, `[{"id":1,"url":"https://event.api.pinpoint.com/hook/1","token":"hook-token"}]`)
+
+	cassette := NewCassette(server.URL, Token)
+	recording := &HTTPClientManager{Transport: cassette.Recorder(http.DefaultTransport)}
+	client := recording.New(server.URL+RESTPrefix, map[string]string{"Authorization": "bearer " + Token})
+	graphql := recording.New(server.URL+GraphqlPath, map[string]string{"Authorization": "bearer " + Token})
+	var hooks []map[string]interface{}
+	_, err := client.Get(&hooks, sdk.WithEndpoint("projects/100/hooks"), sdk.WithGetQueryParameters(map[string][]string{"private_token": {Token}}))
+	assert.NoError(err)
+	assert.Equal("hook-token", hooks[0]["token"])
+	_, err = graphql.Post(strings.NewReader(`{"query":"query ProjectIssues($fullPath: ID!) { project(fullPath: $fullPath) { id } }","variables":{"fullPath":"acme/widgets"}}`), nil)
+	assert.NoError(err)
+
+	file := filepath.Join(t.TempDir(), "cassette.json")
+	assert.NoError(cassette.Save(file))
+	loaded, err := LoadCassette(file)
+	assert.NoError(err)
+	assert.Equal(server.URL, loaded.URL)
+	if assert.Len(loaded.Interactions, 2) {
+		assert.Equal("private_token="+Redacted, loaded.Interactions[0].Request.Query)
+		assert.JSONEq(`[{"id":1,"url":"https://event.api.pinpoint.com/hook/1","token":"REDACTED"}]`, string(loaded.Interactions[0].Response.JSON))
+		assert.Equal("ProjectIssues", loaded.Interactions[1].Request.Operation)
+		assert.JSONEq(`{"fullPath":"acme/widgets"}`, string(loaded.Interactions[1].Request.Variables))
+	}
+
+	replaying := &HTTPClientManager{Transport: loaded}
+	replay := replaying.New("http://gitlab.invalid"+RESTPrefix, nil)
+	hooks = nil
+	_, err = replay.Get(&hooks, sdk.WithEndpoint("projects/100/hooks"), sdk.WithGetQueryParameters(map[string][]string{"private_token": {"another-token"}}))
+	assert.NoError(err)
+	assert.Equal(Redacted, hooks[0]["token"])
+	// the graphql requests match by operation and variables, not by query text
+	_, err = replaying.New("http://gitlab.invalid"+GraphqlPath, nil).Post(strings.NewReader(`{"query":"query ProjectIssues($fullPath: ID!) { project(fullPath: $fullPath) { id name } }","variables":{"fullPath":"acme/widgets"}}`), nil)
+	assert.NoError(err)
+	assert.Empty(loaded.Missing())
+
+	_, err = replay.Get(nil, sdk.WithEndpoint("projects/101/hooks"))
+	assert.Error(err)
+	assert.Equal([]string{"GET /api/v4/projects/101/hooks  "}, loaded.Missing())
+}
@@ -0,0 +1,103 @@
+package gitlabtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// HTTPClientManager creates http clients behaving like the agent ones without retries
+type HTTPClientManager struct {
+	// Transport sends the requests, defaults to http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+var _ sdk.HTTPClientManager = (*HTTPClientManager)(nil)
+
+// New returns a client for url sending headers on every request
+func (m *HTTPClientManager) New(url string, headers map[string]string) sdk.HTTPClient {
+	transport := m.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &httpClient{url: url, headers: headers, transport: transport}
+}
+
+type httpClient struct {
+	url       string
+	headers   map[string]string
+	transport http.RoundTripper
+}
+
+var _ sdk.HTTPClient = (*httpClient)(nil)
+
+func (c *httpClient) Get(out interface{}, options ...sdk.WithHTTPOption) (*sdk.HTTPResponse, error) {
+	return c.exec(http.MethodGet, nil, out, options...)
+}
+
+func (c *httpClient) Post(data io.Reader, out interface{}, options ...sdk.WithHTTPOption) (*sdk.HTTPResponse, error) {
+	return c.exec(http.MethodPost, data, out, options...)
+}
+
+func (c *httpClient) Put(data io.Reader, out interface{}, options ...sdk.WithHTTPOption) (*sdk.HTTPResponse, error) {
+	return c.exec(http.MethodPut, data, out, options...)
+}
+
+func (c *httpClient) Patch(data io.Reader, out interface{}, options ...sdk.WithHTTPOption) (*sdk.HTTPResponse, error) {
+	return c.exec(http.MethodPatch, data, out, options...)
+}
+
+func (c *httpClient) Delete(out interface{}, options ...sdk.WithHTTPOption) (*sdk.HTTPResponse, error) {
+	return c.exec(http.MethodDelete, nil, out, options...)
+}
+
+func (c *httpClient) exec(method string, data io.Reader, out interface{}, options ...sdk.WithHTTPOption) (*sdk.HTTPResponse, error) {
+	req, err := http.NewRequest(method, c.url, data)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	opts := &sdk.HTTPOptions{Request: req, Transport: c.transport}
+	for _, option := range options {
+		if err := option(opts); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := (&http.Client{Transport: opts.Transport}).Do(opts.Request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res := &sdk.HTTPResponse{StatusCode: resp.StatusCode, Headers: resp.Header}
+	opts.Response = res
+	for _, option := range options {
+		if err := option(opts); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return res, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, resp.Body); err != nil {
+		return nil, err
+	}
+	res.Body = buf.Bytes()
+	if resp.StatusCode > 299 {
+		return res, &sdk.HTTPError{StatusCode: resp.StatusCode, Body: &buf}
+	}
+	if out == nil || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return res, nil
+	}
+	return res, json.Unmarshal(res.Body, out)
+}
@@ -0,0 +1,164 @@
+package gitlabtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+const (
+	// CustomerID is the customer of the fake events
+	CustomerID = "1234"
+	// IntegrationInstanceID is the integration instance of the fake events
+	IntegrationInstanceID = "5678"
+	// Token is the api token configured for the fake gitlab
+	Token = "glpat-test-token"
+)
+
+// Instance holds what is shared by the export, webhook and mutation events of an integration instance
+type Instance struct {
+	Config sdk.Config
+	State  *State
+	Pipe   *Pipe
+	Logger sdk.Logger
+}
+
+// NewInstance returns an instance configured to use the fake gitlab at url with apikey auth
+func NewInstance(url string) *Instance {
+	return newInstance(url, Token)
+}
+
+func newInstance(url string, token string) *Instance {
+	auth, _ := json.Marshal(map[string]string{"apikey": token, "url": url})
+	return &Instance{
+		Config: sdk.NewConfig(map[string]interface{}{"apikey_auth": string(auth)}),
+		State:  NewState(),
+		Pipe:   &Pipe{},
+		Logger: sdk.NewNoOpTestLogger(),
+	}
+}
+
+type control struct {
+	instance *Instance
+}
+
+func (c control) CustomerID() string            { return CustomerID }
+func (c control) IntegrationInstanceID() string { return IntegrationInstanceID }
+func (c control) RefType() string               { return "gitlab" }
+func (c control) Paused(resetAt time.Time) error {
+	return fmt.Errorf("paused until %s", resetAt)
+}
+func (c control) Resumed() error     { return nil }
+func (c control) Config() sdk.Config { return c.instance.Config }
+func (c control) State() sdk.State   { return c.instance.State }
+func (c control) Pipe() sdk.Pipe     { return c.instance.Pipe }
+func (c control) Logger() sdk.Logger { return c.instance.Logger }
+func (c control) Stats() sdk.Stats   { return sdk.NewStats() }
+func (c control) JobID() string      { return "job" }
+
+// Export is a fake sdk.Export
+type Export struct {
+	control
+	historical bool
+}
+
+var _ sdk.Export = (*Export)(nil)
+
+// Export returns an export of the instance
+func (i *Instance) Export(historical bool) *Export {
+	return &Export{control: control{i}, historical: historical}
+}
+
+// Historical reports if it's a historical export
+func (e *Export) Historical() bool { return e.historical }
+
+// WebHook is a fake sdk.WebHook
+type WebHook struct {
+	control
+	refID   string
+	url     string
+	body    []byte
+	headers map[string]string
+}
+
+var _ sdk.WebHook = (*WebHook)(nil)
+
+// WebHook returns a webhook of the instance for event with body, the headers keys are lowercase like the agent sends them
+func (i *Instance) WebHook(event string, body []byte, headers map[string]string) *WebHook {
+	h := map[string]string{"x-gitlab-event": event}
+	for k, v := range headers {
+		h[k] = v
+	}
+	return &WebHook{control: control{i}, url: WebHookURL, body: body, headers: h}
+}
+
+// WithURL sets the url of the hook the delivery was sent to
+func (w *WebHook) WithURL(url string) *WebHook {
+	w.url = url
+	return w
+}
+
+// RefID returns the ref_id of the hook
+func (w *WebHook) RefID() string { return w.refID }
+
+// Data returns the body decoded
+func (w *WebHook) Data() (map[string]interface{}, error) {
+	var data map[string]interface{}
+	return data, json.Unmarshal(w.body, &data)
+}
+
+// Scope returns the hook scope
+func (w *WebHook) Scope() sdk.WebHookScope { return sdk.WebHookScopeRepo }
+
+// Bytes returns the body
+func (w *WebHook) Bytes() []byte { return w.body }
+
+// URL returns the hook url
+func (w *WebHook) URL() string { return w.url }
+
+// Headers returns the headers
+func (w *WebHook) Headers() map[string]string { return w.headers }
+
+// Mutation is a fake sdk.Mutation
+type Mutation struct {
+	control
+	id      string
+	refID   string
+	model   string
+	action  sdk.MutationAction
+	payload interface{}
+	user    sdk.MutationUser
+}
+
+var _ sdk.Mutation = (*Mutation)(nil)
+
+// Mutation returns a mutation of the instance on behalf of a user authorized with the fake gitlab token
+func (i *Instance) Mutation(id string, refID string, model string, action sdk.MutationAction, payload interface{}) *Mutation {
+	var user sdk.MutationUser
+	buf, _ := json.Marshal(map[string]interface{}{
+		"ref_id":      "1",
+		"apikey_auth": i.Config.APIKeyAuth,
+	})
+	json.Unmarshal(buf, &user)
+	return &Mutation{control: control{i}, id: id, refID: refID, model: model, action: action, payload: payload, user: user}
+}
+
+// ID returns the id of the model
+func (m *Mutation) ID() string { return m.id }
+
+// RefID returns the ref_id of the model
+func (m *Mutation) RefID() string { return m.refID }
+
+// Model returns the model name
+func (m *Mutation) Model() string { return m.model }
+
+// Action returns the mutation action
+func (m *Mutation) Action() sdk.MutationAction { return m.action }
+
+// Payload returns the mutation payload
+func (m *Mutation) Payload() interface{} { return m.payload }
+
+// User returns the user of the mutation
+func (m *Mutation) User() sdk.MutationUser { return m.user }
@@ -0,0 +1,94 @@
+package gitlabtest
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// Record summarizes a model written to the pipe. The ids are hashes of the ref ids so they
+// are left out, only the ref id and a few descriptive fields are compared
+type Record struct {
+	Model  string                 `json:"model"`
+	RefID  string                 `json:"ref_id"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// recordFields are the fields added to the records when they are set
+var recordFields = []string{"Name", "Title", "Identifier", "URL", "Sha", "Body", "RequestedReviewerRefID", "Active"}
+
+// Records returns the records of the models sorted by model, ref id and fields
+func Records(models []sdk.Model) []Record {
+	records := make([]Record, 0, len(models))
+	for _, m := range models {
+		r := Record{Model: fmt.Sprint(m.GetModelName())}
+		v := reflect.Indirect(reflect.ValueOf(m))
+		if f := v.FieldByName("RefID"); f.IsValid() && f.Kind() == reflect.String {
+			r.RefID = f.String()
+		}
+		for _, name := range recordFields {
+			f := v.FieldByName(name)
+			if !f.IsValid() {
+				continue
+			}
+			f = reflect.Indirect(f)
+			if !f.IsValid() || f.IsZero() {
+				continue
+			}
+			switch f.Kind() {
+			case reflect.String, reflect.Bool:
+				if r.Fields == nil {
+					r.Fields = make(map[string]interface{})
+				}
+				r.Fields[name] = f.Interface()
+			}
+		}
+		records = append(records, r)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Model != records[j].Model {
+			return records[i].Model < records[j].Model
+		}
+		if records[i].RefID != records[j].RefID {
+			return records[i].RefID < records[j].RefID
+		}
+		return fmt.Sprint(records[i].Fields) < fmt.Sprint(records[j].Fields)
+	})
+	return records
+}
+
+// AssertGolden compares got encoded as json with the golden file, the file is rewritten
+// when the tests run with -update
+func AssertGolden(t *testing.T, file string, got interface{}) {
+	t.Helper()
+	buf, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf = append(buf, '\n')
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, buf, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("error reading golden file, run the tests with -update to create it: %s", err)
+	}
+	assert.Equal(t, string(want), string(buf), "golden file %s", file)
+}
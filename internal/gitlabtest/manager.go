@@ -0,0 +1,184 @@
+package gitlabtest
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// Manager is a fake sdk.Manager, only the http and webhook managers are available
+type Manager struct {
+	HTTP     *HTTPClientManager
+	WebHooks *WebHookManager
+}
+
+var _ sdk.Manager = (*Manager)(nil)
+
+// NewManager returns a manager with real http clients and a fake webhook manager
+func NewManager() *Manager {
+	return &Manager{
+		HTTP:     &HTTPClientManager{},
+		WebHooks: NewWebHookManager(),
+	}
+}
+
+// GraphQLManager isn't used by the integration, the graphql api is called through the http manager
+func (m *Manager) GraphQLManager() sdk.GraphQLClientManager { return nil }
+
+// HTTPManager returns the http client manager
+func (m *Manager) HTTPManager() sdk.HTTPClientManager { return m.HTTP }
+
+// WebHookManager returns the fake webhook manager
+func (m *Manager) WebHookManager() sdk.WebHookManager { return m.WebHooks }
+
+// AuthManager isn't available
+func (m *Manager) AuthManager() sdk.AuthManager { return nil }
+
+// UserManager isn't available
+func (m *Manager) UserManager() sdk.UserManager { return nil }
+
+// Close does nothing
+func (m *Manager) Close() error { return nil }
+
+// WebHookURL is the prefix of the urls of the hooks created by the fake webhook manager
+const WebHookURL = "https://event.api.pinpoint.com/hook/"
+
+// WebHookSecret is the secret of a new fake webhook manager
+const WebHookSecret = "webhook-secret"
+
+// WebHookManager is a fake sdk.WebHookManager keeping the hooks in memory
+type WebHookManager struct {
+	mu     sync.Mutex
+	hooks  map[string]string
+	errors map[string]error
+	secret string
+	// renew makes each Create return a new url, like the hooks registered again by the agent
+	renew   bool
+	creates int
+}
+
+var _ sdk.WebHookManager = (*WebHookManager)(nil)
+
+// NewWebHookManager returns an empty webhook manager
+func NewWebHookManager() *WebHookManager {
+	return &WebHookManager{
+		hooks:  make(map[string]string),
+		errors: make(map[string]error),
+		secret: WebHookSecret,
+	}
+}
+
+func webHookKey(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) string {
+	return strings.Join([]string{customerID, integrationInstanceID, refType, string(scope), refID}, "/")
+}
+
+// Create creates a hook, the params are added to the hook url query
+func (m *WebHookManager) Create(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope, params ...string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := webHookKey(customerID, integrationInstanceID, refType, refID, scope)
+	theurl := WebHookURL + key
+	if m.renew {
+		m.creates++
+		params = append(params, fmt.Sprintf("renewed=%d", m.creates))
+	}
+	if len(params) > 0 {
+		theurl += "?" + strings.Join(params, "&")
+	}
+	m.hooks[key] = theurl
+	delete(m.errors, key)
+	return theurl, nil
+}
+
+// Delete deletes a hook
+func (m *WebHookManager) Delete(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.hooks, webHookKey(customerID, integrationInstanceID, refType, refID, scope))
+	return nil
+}
+
+// Exists reports if the hook was created
+func (m *WebHookManager) Exists(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.hooks[webHookKey(customerID, integrationInstanceID, refType, refID, scope)]
+	return ok
+}
+
+// Errored records the hook error
+func (m *WebHookManager) Errored(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[webHookKey(customerID, integrationInstanceID, refType, refID, scope)] = err
+}
+
+// HookURL returns the url of a created hook
+func (m *WebHookManager) HookURL(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	theurl, ok := m.hooks[webHookKey(customerID, integrationInstanceID, refType, refID, scope)]
+	if !ok {
+		return "", fmt.Errorf("webhook not found")
+	}
+	return theurl, nil
+}
+
+// CreateSharedWebhook creates a hook without params
+func (m *WebHookManager) CreateSharedWebhook(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) (string, error) {
+	return m.Create(customerID, integrationInstanceID, refType, refID, scope)
+}
+
+// IsPinpointWebhook reports if the url was created by a webhook manager
+func (m *WebHookManager) IsPinpointWebhook(theurl string) bool {
+	u, err := url.Parse(theurl)
+	return err == nil && u.Host == "event.api.pinpoint.com"
+}
+
+// Secret returns the secret shared by the hooks
+func (m *WebHookManager) Secret() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.secret
+}
+
+// SetSecret changes the secret, like a new secret configured in the agent
+func (m *WebHookManager) SetSecret(secret string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secret = secret
+}
+
+// SetRenewURLs makes each Create return a new url
+func (m *WebHookManager) SetRenewURLs(renew bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.renew = renew
+}
+
+// Hooks returns the urls of the hooks created, sorted
+func (m *WebHookManager) Hooks() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var hooks []string
+	for _, theurl := range m.hooks {
+		hooks = append(hooks, theurl)
+	}
+	sort.Strings(hooks)
+	return hooks
+}
+
+// Errors returns the errors recorded by key customer/instance/reftype/scope/ref_id
+func (m *WebHookManager) Errors() map[string]error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	errors := make(map[string]error)
+	for k, v := range m.errors {
+		errors[k] = v
+	}
+	return errors
+}
@@ -0,0 +1,55 @@
+package gitlabtest
+
+import (
+	"sync"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// Pipe is a fake sdk.Pipe recording the models written, safe for concurrent use
+type Pipe struct {
+	mu      sync.Mutex
+	written []sdk.Model
+	flushed int
+	closed  bool
+}
+
+var _ sdk.Pipe = (*Pipe)(nil)
+
+// Write records the model
+func (p *Pipe) Write(object sdk.Model) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.written = append(p.written, object)
+	return nil
+}
+
+// Flush counts the flushes
+func (p *Pipe) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushed++
+	return nil
+}
+
+// Close marks the pipe as closed
+func (p *Pipe) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// Written returns the models written in order
+func (p *Pipe) Written() []sdk.Model {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]sdk.Model(nil), p.written...)
+}
+
+// Reset forgets the models written so far
+func (p *Pipe) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.written = nil
+}
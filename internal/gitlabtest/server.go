@@ -0,0 +1,318 @@
+// Package gitlabtest provides an in-process fake gitlab and fake agent sdk implementations
+// to run the integration end to end without network access
+package gitlabtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RESTPrefix is the path the rest api is served on
+	RESTPrefix = "/api/v4/"
+	// GraphqlPath is the path the graphql api is served on
+	GraphqlPath = "/api/graphql"
+
+	defaultPerPage = 20
+)
+
+// Request is a request received by the fake server
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   string
+	// Operation is the graphql operation name
+	Operation string
+	// Variables are the graphql operation variables
+	Variables map[string]interface{}
+}
+
+func (r Request) String() string {
+	if r.Operation != "" {
+		return "GRAPHQL " + r.Operation
+	}
+	return r.Method + " " + r.Path
+}
+
+// Server is a fake gitlab serving the rest and graphql apis from fixture files.
+//
+// Rest fixtures live in dir/rest, GET requests are answered with dir/rest/<path>.json and
+// other methods with dir/rest/<path>.<method>.json. Array fixtures are paginated honoring
+// per_page, page and keyset pagination, filtered by updated_after, since and iids[]. Missing GET
+// collections are answered with an empty array and the rest of the missing fixtures with an
+// empty object.
+//
+// Graphql fixtures live in dir/graphql, a query is answered with the first file found of
+// dir/graphql/<operation>/<variables>.json and dir/graphql/<operation>.json, where variables
+// are the operation variables formatted as name=value sorted by name and joined by comma,
+// missing fixtures are answered with empty data.
+type Server struct {
+	*httptest.Server
+
+	dir string
+
+	mu        sync.Mutex
+	requests  []Request
+	missing   []string
+	responses map[string]response
+}
+
+type response struct {
+	status int
+	body   string
+}
+
+// NewServer starts a fake gitlab serving the fixtures in dir
+func NewServer(dir string) *Server {
+	s := &Server{dir: dir, responses: make(map[string]response)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(RESTPrefix, s.serveREST)
+	mux.HandleFunc(GraphqlPath, s.serveGraphql)
+	mux.HandleFunc(GraphqlPath+"/", s.serveGraphql)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Respond overrides the response of method and path, path is relative to the rest api
+func (s *Server) Respond(method string, path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[method+" "+strings.Trim(path, "/")] = response{status, body}
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Missing returns the requests which had no fixture, sorted and without duplicates
+func (s *Server) Missing() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	var missing []string
+	for _, m := range s.missing {
+		if !seen[m] {
+			seen[m] = true
+			missing = append(missing, m)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func (s *Server) record(r Request, found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	if !found {
+		s.missing = append(s.missing, r.String())
+	}
+}
+
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, RESTPrefix), "/")
+	req := Request{Method: r.Method, Path: path, Query: r.URL.Query(), Body: string(body)}
+
+	s.mu.Lock()
+	override, ok := s.responses[r.Method+" "+path]
+	s.mu.Unlock()
+	if ok {
+		s.record(req, true)
+		writeJSON(w, override.status, []byte(override.body))
+		return
+	}
+
+	file := filepath.Join(s.dir, "rest", filepath.FromSlash(path)+".json")
+	if r.Method != http.MethodGet {
+		file = filepath.Join(s.dir, "rest", filepath.FromSlash(path)+"."+strings.ToLower(r.Method)+".json")
+	}
+	buf, err := ioutil.ReadFile(file)
+	s.record(req, err == nil)
+	if err != nil {
+		switch r.Method {
+		case http.MethodGet:
+			buf = []byte("[]")
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		default:
+			buf = []byte("{}")
+		}
+	}
+
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+
+	var items []json.RawMessage
+	if r.Method != http.MethodGet || json.Unmarshal(buf, &items) != nil {
+		writeJSON(w, status, buf)
+		return
+	}
+
+	items = filterItems(items, req.Query)
+	page, next := paginate(items, req.Query)
+	out, _ := json.Marshal(page)
+
+	w.Header().Set("X-Total", strconv.Itoa(len(items)))
+	if next > 0 {
+		q := url.Values{}
+		for k, v := range req.Query {
+			q[k] = v
+		}
+		if req.Query.Get("pagination") == "keyset" {
+			q.Set("cursor", strconv.Itoa(next))
+			link := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path, RawQuery: q.Encode()}
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, link.String()))
+		} else {
+			w.Header().Set("X-Next-Page", strconv.Itoa(next))
+		}
+	}
+	writeJSON(w, status, out)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// filterItems applies the filters gitlab supports on most collections
+func filterItems(items []json.RawMessage, query url.Values) []json.RawMessage {
+	updatedAfter, _ := time.Parse(time.RFC3339, query.Get("updated_after"))
+	since, _ := time.Parse(time.RFC3339, query.Get("since"))
+	iids := query["iids[]"]
+	// a revision range from..to lists the commits up to the from sha, the fixtures are newest first
+	var from string
+	if i := strings.Index(query.Get("ref_name"), ".."); i > 0 {
+		from = query.Get("ref_name")[:i]
+	}
+	if updatedAfter.IsZero() && since.IsZero() && len(iids) == 0 && from == "" {
+		return items
+	}
+	var res []json.RawMessage
+	for _, item := range items {
+		var fields struct {
+			ID            string      `json:"id"`
+			IID           json.Number `json:"iid"`
+			UpdatedAt     time.Time   `json:"updated_at"`
+			CommittedDate time.Time   `json:"committed_date"`
+		}
+		json.Unmarshal(item, &fields)
+		if from != "" && fields.ID == from {
+			break
+		}
+		if !updatedAfter.IsZero() && fields.UpdatedAt.Before(updatedAfter) {
+			continue
+		}
+		if !since.IsZero() && fields.CommittedDate.Before(since) {
+			continue
+		}
+		if len(iids) > 0 && !contains(iids, fields.IID.String()) {
+			continue
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate returns the requested page and the number of the next one, zero if it's the last page,
+// keyset requests use the cursor as page number
+func paginate(items []json.RawMessage, query url.Values) ([]json.RawMessage, int) {
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	if query.Get("pagination") == "keyset" {
+		page, _ = strconv.Atoi(query.Get("cursor"))
+	}
+	if page <= 0 {
+		page = 1
+	}
+	start := (page - 1) * perPage
+	if start >= len(items) {
+		return []json.RawMessage{}, 0
+	}
+	end := start + perPage
+	if end >= len(items) {
+		return items[start:], 0
+	}
+	return items[start:end], page + 1
+}
+
+var graphqlOperationName = regexp.MustCompile(`^\s*(query|mutation)\s+(\w+)`)
+
+func (s *Server) serveGraphql(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, []byte(`{"errors":[{"message":"invalid request body"}]}`))
+		return
+	}
+	req := Request{Method: r.Method, Path: GraphqlPath, Body: payload.Query, Variables: payload.Variables, Operation: "anonymous"}
+	if match := graphqlOperationName.FindStringSubmatch(payload.Query); match != nil {
+		req.Operation = match[2]
+	}
+
+	candidates := []string{
+		filepath.Join(s.dir, "graphql", req.Operation, variablesKey(payload.Variables)+".json"),
+		filepath.Join(s.dir, "graphql", req.Operation+".json"),
+	}
+	for _, file := range candidates {
+		if buf, err := ioutil.ReadFile(file); err == nil {
+			s.record(req, true)
+			writeJSON(w, http.StatusOK, buf)
+			return
+		} else if !os.IsNotExist(err) {
+			writeJSON(w, http.StatusInternalServerError, []byte(fmt.Sprintf(`{"errors":[{"message":%q}]}`, err.Error())))
+			return
+		}
+	}
+	s.record(req, false)
+	writeJSON(w, http.StatusOK, []byte(`{"data":{}}`))
+}
+
+var unsafePathChars = regexp.MustCompile(`[^\w.=,-]+`)
+
+// variablesKey formats the scalar variables as name=value sorted by name
+func variablesKey(variables map[string]interface{}) string {
+	var keys []string
+	for k, v := range variables {
+		switch v.(type) {
+		case string, float64, bool:
+			keys = append(keys, fmt.Sprintf("%s=%v", k, v))
+		}
+	}
+	sort.Strings(keys)
+	return unsafePathChars.ReplaceAllString(strings.Join(keys, ","), "_")
+}
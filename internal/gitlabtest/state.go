@@ -0,0 +1,112 @@
+package gitlabtest
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// State is a fake sdk.State, values are stored as json like the agent does
+type State struct {
+	mu      sync.Mutex
+	values  map[string][]byte
+	expires map[string]time.Time
+	// Now is used to expire the values, defaults to time.Now
+	Now func() time.Time
+}
+
+var _ sdk.State = (*State)(nil)
+
+// NewState returns an empty state
+func NewState() *State {
+	return &State{
+		values:  make(map[string][]byte),
+		expires: make(map[string]time.Time),
+		Now:     time.Now,
+	}
+}
+
+// Set sets a value
+func (s *State) Set(key string, value interface{}) error {
+	return s.SetWithExpires(key, value, 0)
+}
+
+// SetWithExpires sets a value which expires after expiry, zero never expires
+func (s *State) SetWithExpires(key string, value interface{}, expiry time.Duration) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = buf
+	if expiry > 0 {
+		s.expires[key] = s.Now().Add(expiry)
+	} else {
+		delete(s.expires, key)
+	}
+	return nil
+}
+
+// Get gets a value into out, returns false if not found
+func (s *State) Get(key string, out interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf, ok := s.get(key)
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(buf, out)
+}
+
+func (s *State) get(key string) ([]byte, bool) {
+	buf, ok := s.values[key]
+	if !ok {
+		return nil, false
+	}
+	if expires, ok := s.expires[key]; ok && !s.Now().Before(expires) {
+		delete(s.values, key)
+		delete(s.expires, key)
+		return nil, false
+	}
+	return buf, true
+}
+
+// Exists reports if the key exists
+func (s *State) Exists(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.get(key)
+	return ok
+}
+
+// Delete deletes a key
+func (s *State) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	delete(s.expires, key)
+	return nil
+}
+
+// Flush does nothing, the values are kept in memory
+func (s *State) Flush() error {
+	return nil
+}
+
+// Keys returns the keys not expired, sorted
+func (s *State) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.values {
+		if _, ok := s.get(k); ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -9,7 +9,7 @@ import (
 )
 
 func (ge *GitlabExport) exportIssueComments(repo *api.GitlabProjectInternal, pr api.PullRequest) error {
-	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, stopOnUpdatedAt time.Time) (pi api.NextPage, rerr error) {
+	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (pi api.NextPage, rerr error) {
 		pi, comments, err := api.PullRequestCommentsPage(ge.qc, repo, pr, params)
 		if err != nil {
 			return pi, err
@@ -17,7 +17,7 @@ func (ge *GitlabExport) exportIssueComments(repo *api.GitlabProjectInternal, pr
 		for _, c := range comments {
 			c.IntegrationInstanceID = ge.integrationInstanceID
 			if err := ge.pipe.Write(c); err != nil {
-				return
+				return pi, err
 			}
 		}
 		return
@@ -20,7 +20,7 @@ func (g *GitlabIntegration) Mutation(mutation sdk.Mutation) (*sdk.MutationRespon
 	c.BasicAuth = user.BasicAuth
 	c.OAuth2Auth = user.OAuth2Auth
 
-	ge, err := g.SetQueryConfig(logger, c, g.manager, mutation.CustomerID())
+	ge, err := g.SetQueryConfig(g.context(), logger, c, g.manager, mutation.CustomerID())
 	if err != nil {
 		return nil, err
 	}
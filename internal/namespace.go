@@ -1,6 +1,11 @@
 package internal
 
 import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/gitlab/internal/api"
 )
@@ -28,3 +33,117 @@ func getNamespacesSelectedAccounts(qc api.QueryContext, accounts *sdk.ConfigAcco
 
 	return filteredNamespaces, nil
 }
+
+// exportedNamespacesKey is the state key of the namespaces the last export went through, group id
+// to full path. The system hooks are sent for the whole instance and only change these
+const exportedNamespacesKey = "exported_namespaces"
+
+// namespacesExpiry is how long the namespaces and their members are kept without an export
+const namespacesExpiry = 30 * 24 * time.Hour
+
+// setExportedNamespaces keeps the namespaces of the export, the members of the namespaces no
+// longer exported are dropped
+func setExportedNamespaces(state sdk.State, namespaces []*api.Namespace) error {
+	previous, err := exportedNamespaces(state)
+	if err != nil {
+		return err
+	}
+	exported := make(map[string]string)
+	for _, namespace := range namespaces {
+		exported[namespace.ID] = namespace.FullPath
+		delete(previous, namespace.ID)
+	}
+	for id := range previous {
+		groupID, _ := strconv.ParseInt(id, 10, 64)
+		if err := state.Delete(namespaceMembersKey(groupID)); err != nil {
+			return err
+		}
+	}
+	return state.SetWithExpires(exportedNamespacesKey, exported, namespacesExpiry)
+}
+
+// exportedNamespaces returns the namespaces of the last export, group id to full path
+func exportedNamespaces(state sdk.State) (map[string]string, error) {
+	exported := make(map[string]string)
+	if _, err := state.Get(exportedNamespacesKey, &exported); err != nil {
+		return nil, err
+	}
+	return exported, nil
+}
+
+// namespaceMembersKey is the state key of the members of an exported group, user id to access
+// level. The exports write it and the system hooks keep it current between them
+func namespaceMembersKey(groupID int64) string {
+	return "namespace_members_" + strconv.FormatInt(groupID, 10)
+}
+
+// namespaceMembers returns the members of a group
+func namespaceMembers(state sdk.State, groupID int64) (map[string]string, error) {
+	members := make(map[string]string)
+	if _, err := state.Get(namespaceMembersKey(groupID), &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// setNamespaceMember sets the access level of a group member, an empty access level removes the
+// member. Only the members of the exported groups are kept
+func setNamespaceMember(state sdk.State, groupID int64, userID int64, access string) error {
+	exported, err := exportedNamespaces(state)
+	if err != nil {
+		return err
+	}
+	if _, ok := exported[strconv.FormatInt(groupID, 10)]; !ok {
+		return nil
+	}
+	members, err := namespaceMembers(state, groupID)
+	if err != nil {
+		return err
+	}
+	if access == "" {
+		delete(members, strconv.FormatInt(userID, 10))
+	} else {
+		members[strconv.FormatInt(userID, 10)] = access
+	}
+	if len(members) == 0 {
+		return state.Delete(namespaceMembersKey(groupID))
+	}
+	return state.SetWithExpires(namespaceMembersKey(groupID), members, namespacesExpiry)
+}
+
+// exportNamespaceMembers keeps the direct members of the group, so the system hooks removing the
+// group can update them
+func (ge *GitlabExport) exportNamespaceMembers(namespace *api.Namespace) error {
+
+	logger := sdk.LogWith(ge.logger, "namespace_id", namespace.ID, "namespace_name", namespace.Name)
+
+	if namespace.Kind != "group" {
+		return nil
+	}
+
+	members := make(map[string]string)
+	err := api.Paginate(logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (api.NextPage, error) {
+		pi, arr, err := api.GroupMembersPage(ge.qc, namespace, params)
+		if err != nil {
+			return pi, err
+		}
+		for _, member := range arr {
+			members[member.StrID] = api.AccessLevelName(member.AccessLevel)
+		}
+		return pi, nil
+	})
+	// only the members of the group can list them
+	if errors.Is(err, api.ErrForbidden) || errors.Is(err, api.ErrNotFound) {
+		sdk.LogDebug(logger, "skipping group members, the members can't be listed", "err", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	groupID, _ := strconv.ParseInt(namespace.ID, 10, 64)
+	if len(members) == 0 {
+		return ge.state.Delete(namespaceMembersKey(groupID))
+	}
+	return ge.state.SetWithExpires(namespaceMembersKey(groupID), members, namespacesExpiry)
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+)
+
+// the status of the pipelines is kept for a while, the job hooks only write the pipeline again
+// when its status changed
+const pipelineStatusKeyPrefix = "pipeline_status_"
+
+const pipelineStatusExpiry = 30 * 24 * time.Hour
+
+// pipelinesHistoricalLimit is the number of the most recent pipelines of a repo a historical export fetches
+const pipelinesHistoricalLimit = 1000
+
+// pipelineStatusKey is the state key of the status of the pipeline build
+func pipelineStatusKey(buildID string) string {
+	return pipelineStatusKeyPrefix + buildID
+}
+
+// exportRepoPipelines exports the pipelines of the repo and their jobs, incrementals only the ones updated
+// since the last export and historicals only the most recent ones
+func (ge *GitlabExport) exportRepoPipelines(repo *api.GitlabProjectInternal) error {
+
+	logger := sdk.LogWith(ge.logger, "repo", repo.Name, "repo_ref_id", repo.RefID)
+
+	var count int
+	err := api.Paginate(logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (api.NextPage, error) {
+		if ge.lastExportDateGitlabFormat != "" {
+			params.Set("updated_after", ge.lastExportDateGitlabFormat)
+		}
+		// the newest first
+		pi, pipelines, err := api.PipelinesPage(ge.qc, repo, params)
+		if err != nil {
+			return pi, err
+		}
+		for _, p := range pipelines {
+			if ge.lastExportDateGitlabFormat == "" && count == pipelinesHistoricalLimit {
+				sdk.LogInfo(logger, "pipelines limit reached, skipping the older ones", "limit", pipelinesHistoricalLimit)
+				return "", nil
+			}
+			if err := ge.exportPipeline(repo, p.ID); err != nil {
+				return pi, err
+			}
+			count++
+		}
+		return pi, nil
+	})
+	// the pipelines are forbidden when ci is disabled in the repo
+	if errors.Is(err, api.ErrForbidden) {
+		sdk.LogDebug(logger, "skipping pipelines, ci is disabled")
+		return nil
+	}
+	return err
+}
+
+// exportPipeline writes the pipeline and its jobs as builds
+func (ge *GitlabExport) exportPipeline(repo *api.GitlabProjectInternal, pipelineID int64) error {
+
+	if err := ge.exportPipelineBuild(repo, pipelineID); err != nil {
+		return err
+	}
+
+	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (api.NextPage, error) {
+		pi, jobs, err := api.PipelineJobsPage(ge.qc, repo, pipelineID, params)
+		if err != nil {
+			return pi, err
+		}
+		for _, j := range jobs {
+			if err := ge.writeJobBuild(repo, j); err != nil {
+				return pi, err
+			}
+		}
+		return pi, nil
+	})
+}
+
+// exportPipelineBuild writes the pipeline as a build
+func (ge *GitlabExport) exportPipelineBuild(repo *api.GitlabProjectInternal, pipelineID int64) error {
+
+	pipeline, err := api.PipelineByID(ge.qc, repo, pipelineID)
+	if err != nil {
+		return err
+	}
+
+	build := pipeline.ToCICDBuild(ge.qc.CustomerID, gitlabRefType, repo.FullPath)
+	build.IntegrationInstanceID = ge.integrationInstanceID
+	if err := ge.pipe.Write(build); err != nil {
+		return err
+	}
+
+	return ge.state.SetWithExpires(pipelineStatusKey(build.ID), pipeline.Status, pipelineStatusExpiry)
+}
+
+// pipelineStatusChanged reports if the status of the pipeline differs from the one written last
+func (ge *GitlabExport) pipelineStatusChanged(pipelineID int64, status string) (bool, error) {
+	var last string
+	found, err := ge.state.Get(pipelineStatusKey(sdk.NewCICDBuildID(ge.qc.CustomerID, gitlabRefType, strconv.FormatInt(pipelineID, 10))), &last)
+	if err != nil {
+		return false, err
+	}
+	return !found || last != status, nil
+}
+
+func (ge *GitlabExport) writeJobBuild(repo *api.GitlabProjectInternal, job *api.Job) error {
+	build := job.ToCICDBuild(ge.qc.CustomerID, gitlabRefType, repo.FullPath)
+	build.IntegrationInstanceID = ge.integrationInstanceID
+	return ge.pipe.Write(build)
+}
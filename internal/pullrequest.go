@@ -109,7 +109,7 @@ func (ge *GitlabExport) fetchInitialRepoPullRequests(repo *api.GitlabProjectInte
 }
 
 func (ge *GitlabExport) fetchRemainingRepoPullRequests(repo *api.GitlabProjectInternal, prs chan api.PullRequest) (rerr error) {
-	rerr = api.Paginate(ge.logger, "2", time.Time{}, func(log sdk.Logger, params url.Values, _ time.Time) (pi api.NextPage, rerr error) {
+	rerr = api.Paginate(ge.logger, "2", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (pi api.NextPage, rerr error) {
 		if ge.lastExportDateGitlabFormat != "" {
 			params.Set("updated_after", ge.lastExportDateGitlabFormat)
 		}
@@ -9,15 +9,15 @@ import (
 )
 
 func (ge *GitlabExport) exportPullRequestsComments(repo *api.GitlabProjectInternal, pr api.PullRequest) error {
-	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (pi api.NextPage, rerr error) {
-		pi, comments, err := api.PullRequestCommentsPage(ge.qc, repo, pr, params)
+	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (pi api.NextPage, rerr error) {
+		pi, comments, err := api.PullRequestDiscussionsPage(ge.qc, repo, pr, params)
 		if err != nil {
 			return pi, err
 		}
 		for _, c := range comments {
 			c.IntegrationInstanceID = ge.integrationInstanceID
 			if err := ge.pipe.Write(c); err != nil {
-				return
+				return pi, err
 			}
 		}
 		return
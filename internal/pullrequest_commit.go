@@ -9,8 +9,8 @@ import (
 )
 
 func (ge *GitlabExport) fetchPullRequestsCommits(repo *api.GitlabProjectInternal, pr api.PullRequest) (commits []*sdk.SourceCodePullRequestCommit, rerr error) {
-	rerr = api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (api.NextPage, error) {
-		pi, commitsArr, err := api.PullRequestCommitsPage(ge.qc, repo, pr, params, t)
+	rerr = api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, stop *api.PageStop) (api.NextPage, error) {
+		pi, commitsArr, err := api.PullRequestCommitsPage(ge.qc, repo, pr, params, stop)
 		if err != nil {
 			return pi, err
 		}
@@ -23,8 +23,8 @@ func (ge *GitlabExport) fetchPullRequestsCommits(repo *api.GitlabProjectInternal
 }
 
 func (ge *GitlabExport) FetchPullRequestsCommitsAfter(repo *api.GitlabProjectInternal, pr api.PullRequest, after time.Time) (commits []*sdk.SourceCodePullRequestCommit, rerr error) {
-	rerr = api.Paginate(ge.logger, "", after, func(log sdk.Logger, params url.Values, t time.Time) (api.NextPage, error) {
-		pi, commitsArr, err := api.PullRequestCommitsPage(ge.qc, repo, pr, params, t)
+	rerr = api.Paginate(ge.logger, "", after, func(log sdk.Logger, params url.Values, stop *api.PageStop) (api.NextPage, error) {
+		pi, commitsArr, err := api.PullRequestCommitsPage(ge.qc, repo, pr, params, stop)
 		if err != nil {
 			return pi, err
 		}
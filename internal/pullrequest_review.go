@@ -1,25 +1,24 @@
 package internal
 
 import (
-	"net/url"
-	"time"
-
-	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/gitlab/internal/api"
 )
 
 func (ge *GitlabExport) exportPullRequestsReviews(repo *api.GitlabProjectInternal, pr api.PullRequest) error {
-	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (np api.NextPage, rerr error) {
-		pi, reviews, err := api.PullRequestReviews(ge.qc, repo, pr, params)
-		if err != nil {
-			return pi, err
+	timeline, err := api.PullRequestReviews(ge.qc, repo, pr)
+	if err != nil {
+		return err
+	}
+	for _, r := range timeline.Reviews {
+		r.IntegrationInstanceID = ge.integrationInstanceID
+		if err := ge.pipe.Write(r); err != nil {
+			return err
 		}
-		for _, c := range reviews {
-			c.IntegrationInstanceID = ge.integrationInstanceID
-			if err := ge.pipe.Write(c); err != nil {
-				return
-			}
+	}
+	for _, r := range timeline.Requests {
+		if err := ge.pipe.Write(r); err != nil {
+			return err
 		}
-		return
-	})
+	}
+	return nil
 }
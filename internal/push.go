@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+)
+
+func (ge *GitlabExport) handlePush(repo *api.GitlabProjectInternal, push *api.WebhookPush) error {
+
+	logger := sdk.LogWith(ge.logger, "repo", repo.Name, "branch", push.Branch())
+
+	if push.Deleted() {
+		sdk.LogDebug(logger, "branch deleted")
+		return nil
+	}
+
+	repoID := sdk.NewSourceCodeRepoID(ge.qc.CustomerID, repo.RefID, gitlabRefType)
+
+	var commits []*sdk.SourceCodeCommit
+
+	if push.Truncated() {
+		// gitlab only sends the first commits, the whole range is fetched
+		from := push.Before
+		if push.Created() {
+			from = repo.DefaultBranch
+		}
+		sdk.LogDebug(logger, "push commits truncated, fetching the range", "total", push.TotalCommitsCount, "from", from, "to", push.After)
+		var err error
+		commits, err = api.CompareCommits(ge.qc, repo, from, push.After)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, c := range push.Commits {
+			author := &api.GitUser{Name: c.Author.Name, Email: c.Author.Email}
+			if err := ge.qc.UserManager.EmitGitUser(logger, author); err != nil {
+				return err
+			}
+			commits = append(commits, c.ToSourceCodeCommit(ge.qc.CustomerID, gitlabRefType, repoID))
+		}
+	}
+
+	sdk.LogDebug(logger, "commits found", "len", len(commits))
+
+	for _, c := range commits {
+		c.IntegrationInstanceID = ge.integrationInstanceID
+		if err := ge.pipe.Write(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,7 +2,6 @@ package internal
 
 import (
 	"net/url"
-	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/gitlab/internal/api"
@@ -11,17 +10,18 @@ import (
 type callback func(item *api.GitlabProjectInternal)
 
 func (ge *GitlabExport) fetchNamespaceProjectsRepos(namespace *api.Namespace, appendItem callback) (rerr error) {
-	return api.Paginate(ge.logger, "", ge.lastExportDate, func(log sdk.Logger, params url.Values, stopOnUpdatedAt time.Time) (api.NextPage, error) {
+	// namespaces can hold more projects than offset pagination is able to reach
+	return api.PaginateKeyset(ge.logger, "id", func(log sdk.Logger, params url.Values, _ *api.PageStop) (api.NextPage, error) {
 		var arr []*api.GitlabProjectInternal
 		var np api.NextPage
 		var err error
 		if namespace.Kind == "group" {
-			np, arr, err = api.GroupNamespaceReposPage(ge.qc, namespace, params, stopOnUpdatedAt)
+			np, arr, err = api.GroupNamespaceReposPage(ge.qc, namespace, params)
 			if err != nil {
 				return np, err
 			}
 		} else {
-			np, arr, err = api.UserReposPage(ge.qc, namespace, params, stopOnUpdatedAt)
+			np, arr, err = api.UserReposPage(ge.qc, namespace, params)
 			if err != nil {
 				return np, err
 			}
@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/gitlab/internal/api"
 )
@@ -99,6 +102,95 @@ func (r *RepoProjectManager) DeactivateReposAndProjects() error {
 
 }
 
+// DeactivateRepoAndProject deactivates a repo removed from gitlab, the exported repo is used
+// when there is one so the rest of its fields are kept
+func (r *RepoProjectManager) DeactivateRepoAndProject(repo *api.GitlabProjectInternal) error {
+
+	stateRepos := make(stateRepos)
+
+	_, err := r.state.Get(reposProjectsProcessedKey, &stateRepos)
+	if err != nil {
+		return err
+	}
+
+	if exported, ok := stateRepos[repo.ID]; ok {
+		repo = exported
+		delete(stateRepos, repo.ID)
+	}
+
+	sdk.LogDebug(r.logger, "deactivating repo", "repo", repo)
+	if err := r.deactivateRepoAndProject(repo); err != nil {
+		return err
+	}
+
+	return r.state.Set(reposProjectsProcessedKey, stateRepos)
+}
+
+// UpdateRepoAndProject writes the repo and its project, the exported repo is replaced with it
+func (r *RepoProjectManager) UpdateRepoAndProject(repo *api.GitlabProjectInternal) error {
+
+	stateRepos := make(stateRepos)
+
+	_, err := r.state.Get(reposProjectsProcessedKey, &stateRepos)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := stateRepos[repo.ID]; ok {
+		stateRepos[repo.ID] = repo
+		if err := r.state.Set(reposProjectsProcessedKey, stateRepos); err != nil {
+			return err
+		}
+	}
+
+	if err := r.pipe.Write(repo); err != nil {
+		return err
+	}
+
+	return r.pipe.Write(ToProject(repo))
+}
+
+// NamespaceRepos returns the exported repos of the namespace with fullPath and its subgroups
+func (r *RepoProjectManager) NamespaceRepos(fullPath string) ([]*api.GitlabProjectInternal, error) {
+
+	stateRepos := make(stateRepos)
+
+	_, err := r.state.Get(reposProjectsProcessedKey, &stateRepos)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]*api.GitlabProjectInternal, 0)
+	for _, repo := range stateRepos {
+		if strings.HasPrefix(repo.FullPath, fullPath+"/") {
+			repos = append(repos, repo)
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].GitlabID < repos[j].GitlabID
+	})
+
+	return repos, nil
+}
+
+// ExportedRepos returns the exported repos by gitlab id
+func (r *RepoProjectManager) ExportedRepos() (map[int64]*api.GitlabProjectInternal, error) {
+
+	stateRepos := make(stateRepos)
+
+	_, err := r.state.Get(reposProjectsProcessedKey, &stateRepos)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make(map[int64]*api.GitlabProjectInternal)
+	for _, repo := range stateRepos {
+		repos[repo.GitlabID] = repo
+	}
+
+	return repos, nil
+}
+
 // NewRepoProjectManager new repo project manager
 func NewRepoProjectManager(logger sdk.Logger, state sdk.State, pipe sdk.Pipe) *RepoProjectManager {
 	return &RepoProjectManager{
@@ -45,7 +45,7 @@ func toWorkUser(user *sdk.SourceCodeUser) *sdk.WorkUser {
 type callBackSourceUser func(item *sdk.SourceCodeUser) error
 
 func (ge *GitlabExport) exportUsers(repo *api.GitlabProjectInternal, callback callBackSourceUser) (rerr error) {
-	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (pi api.NextPage, err error) {
+	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (pi api.NextPage, err error) {
 		pi, arr, err := api.RepoUsersPage(ge.qc, repo, params)
 		if err != nil {
 			return
@@ -67,7 +67,7 @@ func (ge *GitlabExport) exportEnterpriseUsers() error {
 }
 
 func (ge *GitlabExport) fetchEnterpriseUsers(callback callBackSourceUser) (rerr error) {
-	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (pi api.NextPage, err error) {
+	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (pi api.NextPage, err error) {
 		params.Set("membership", "true")
 		pi, arr, err := api.UsersPage(ge.qc, params)
 		if err != nil {
@@ -2,18 +2,17 @@ package internal
 
 import (
 	"encoding/json"
-	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/gitlab/internal/api"
 )
 
-const hookVersion = "2" // change this to upgrade the hook in case the events change
+const hookVersion = "7" // change this to upgrade the hook in case the events change
 
 type user struct {
+	ID        int64  `json:"id"`
 	Name      string `json:"name"`
 	Username  string `json:"username"`
 	Email     string `json:"email"`
@@ -21,6 +20,10 @@ type user struct {
 }
 
 func (u *user) RefID(customerID string) string {
+	// push events don't have the user object
+	if u.Email == "" {
+		return ""
+	}
 	return sdk.Hash(customerID, u.Email)
 }
 
@@ -47,22 +50,26 @@ func (a *user) ToModel(customerID string, integrationInstanceID string) *sdk.Sou
 	return user
 }
 
-type webHookRootPayload struct {
-	WebHookMainObject json.RawMessage `json:"object_attributes"`
-	Project           struct {
-		Name string `json:"name"`
-		ID   int64  `json:"id"`
-	} `json:"project"`
-	User         user                   `json:"user"`
-	Changes      json.RawMessage        `json:"changes"`
-	MergeRequest api.WebhookPullRequest `json:"merge_request"`
-	EventName    string                 `json:"event_name"`
-	ProjectID    int64                  `json:"project_id"`
-	UserID       int64                  `json:"user_id"`
-	Assignees    []user                 `json:"assignees"`
-	Issue        struct {
-		RefID int64 `json:"id"`
-	} `json:"issue"`
+type webHookProject struct {
+	Name              string `json:"name"`
+	ID                int64  `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	DefaultBranch     string `json:"default_branch"`
+	WebURL            string `json:"web_url"`
+}
+
+// toProject returns the project identified like the exported ones, the display name
+// can't be used to call the api
+func (p webHookProject) toProject(customerID string) *api.GitlabProjectInternal {
+	refID := strconv.FormatInt(p.ID, 10)
+	project := &api.GitlabProjectInternal{GitlabID: p.ID, FullPath: p.PathWithNamespace}
+	project.ID = sdk.NewSourceCodeRepoID(customerID, refID, gitlabRefType)
+	project.RefID = refID
+	project.RefType = gitlabRefType
+	project.CustomerID = customerID
+	project.Name = p.PathWithNamespace
+	project.DefaultBranch = p.DefaultBranch
+	return project
 }
 
 // WebHook is called when a webhook is received on behalf of the integration
@@ -75,370 +82,77 @@ func (i *GitlabIntegration) WebHook(webhook sdk.WebHook) (rerr error) {
 
 	pipe := webhook.Pipe()
 
-	event := webhook.Headers()["x-gitlab-event"]
+	event, payload := webhookRoute(webhook.Headers()["x-gitlab-event"], webhook.Bytes())
 
 	state := webhook.State()
 
-	userManager := NewUserManager(customerID, webhook, state, pipe, integrationInstanceID)
-
-	ge, err := i.SetQueryConfig(logger, webhook.Config(), i.manager, customerID)
-	if err != nil {
-		rerr = err
+	rerr = verifyWebhookToken(i.manager.WebHookManager().Secret(), webhook.URL(), webhook.Headers()["x-gitlab-token"])
+	if rerr != nil {
+		sdk.LogWarn(logger, "rejecting webhook", "event", event, "err", rerr)
 		return
 	}
 
-	ge.qc.Pipe = pipe
-	ge.qc.UserManager = userManager
-	ge.qc.WorkManager = NewWorkManager(logger, state)
+	deliveryID, deliverySource := webhookDeliveryID(webhook.Headers(), webhook.Bytes())
+	logger = sdk.LogWith(logger, "delivery_id", deliveryID, "delivery_source", deliverySource)
+
+	var skip bool
+	skip, rerr = skipRedelivery(logger, state, deliveryID)
+	if rerr != nil || skip {
+		return
+	}
+	defer func() {
+		if rerr == nil {
+			rerr = setWebhookDeliveryProcessed(state, deliveryID, event)
+		}
+	}()
 
 	sdk.LogInfo(logger, "event", "event", event)
 
 	sdk.LogDebug(logger, "webhook-body", "body", string(webhook.Bytes()))
 
-	var rootWebHookObject webHookRootPayload
-	rerr = json.Unmarshal(webhook.Bytes(), &rootWebHookObject)
-	if rerr != nil {
-		sdk.LogError(logger, "err", rerr)
+	if payload == nil {
+		sdk.LogWarn(logger, "no handler for webhook event, ignoring", "event", event)
+		rerr = countUnknownWebhookEvent(state, event)
 		return
 	}
 
-	rerr = userManager.EmitGitUser(logger, &rootWebHookObject.User)
+	rerr = json.Unmarshal(webhook.Bytes(), payload)
 	if rerr != nil {
 		sdk.LogError(logger, "err", rerr)
 		return
 	}
 
-	for _, user := range rootWebHookObject.Assignees {
-		rerr = userManager.EmitGitUser(logger, &user)
-		if rerr != nil {
-			sdk.LogError(logger, "err", rerr)
-			return
-		}
-	}
-
-	projectRefID := strconv.FormatInt(rootWebHookObject.Project.ID, 10)
-
-	repoID := sdk.NewSourceCodeRepoID(customerID, projectRefID, gitlabRefType)
-	projectID := sdk.NewWorkProjectID(customerID, projectRefID, gitlabRefType)
-
-	switch event {
-	case "Issue Hook":
-		sdk.LogInfo(logger, "recovering work manager state")
-		if err := ge.qc.WorkManager.Restore(); err != nil {
-			sdk.LogError(logger, "error recovering work manager state", "err", err)
-			return err
-		}
-		issue := &api.IssueWebHook{}
-		err := json.Unmarshal(rootWebHookObject.WebHookMainObject, issue)
-		if err != nil {
-			return err
-		}
-		// Fetch Issue
-		project := &sdk.SourceCodeRepo{}
-		project.ID = projectID
-		project.Name = rootWebHookObject.Project.Name
-		project.RefID = projectRefID
-		if err := ge.writeSingleIssue(project, issue.IID); err != nil {
-			return err
-		}
-		sdk.LogInfo(logger, "persisting work manager into state")
-		if err := ge.qc.WorkManager.Persist(); err != nil {
-			sdk.LogError(logger, "error persisting work manager state", "err", err)
-			return err
-		}
-	case "Merge Request Hook":
-
-		pr := &api.WebhookPullRequest{}
-		rerr = json.Unmarshal(rootWebHookObject.WebHookMainObject, pr)
-		if rerr != nil {
-			return
-		}
-
-		var scPr = &sdk.SourceCodePullRequest{}
-		scPr, rerr = pr.ToSourceCodePullRequest(logger, customerID, repoID, gitlabRefType)
-		if rerr != nil {
-			return
-		}
-		scPr.IntegrationInstanceID = &integrationInstanceID
-
-		switch scPr.Status {
-		case sdk.SourceCodePullRequestStatusClosed:
-			scPr.ClosedByRefID = rootWebHookObject.User.RefID(customerID)
-		case sdk.SourceCodePullRequestStatusMerged:
-			scPr.MergedByRefID = rootWebHookObject.User.RefID(customerID)
-		}
-
-		repo := &api.GitlabProjectInternal{}
-		repo.Name = rootWebHookObject.Project.Name
-		repo.RefID = projectRefID
-
-		prr := api.PullRequest{}
-		prr.SourceCodePullRequest = scPr
-		prr.IID = strconv.FormatInt(pr.IID, 10)
-		_, _, rerr = api.PullRequestReviews(ge.qc, repo, prr, nil)
-		if rerr != nil {
-			return
-		}
-
-		sdk.LogDebug(logger, "source code pull request", "body", scPr.Stringify())
-
-		rerr = pipe.Write(scPr)
-		if rerr != nil {
-			return
-		}
-
-		pullRequestID := sdk.NewSourceCodePullRequestID(customerID, scPr.RefID, gitlabRefType, repoID)
-
-		switch pr.Action {
-		case "approved", "unapproved":
-			review, err := i.GetReviewFromAction(
-				logger,
-				ge.qc,
-				customerID,
-				rootWebHookObject.Project.Name,
-				repoID,
-				scPr.RefID,
-				pullRequestID,
-				scPr.RefID,
-				pr.IID,
-				pr.UpdatedAt,
-				rootWebHookObject.User.Username,
-				pr.Action)
-			if err != nil {
-				rerr = err
-				return
-			}
-
-			review.IntegrationInstanceID = &integrationInstanceID
-
-			rerr = pipe.Write(review)
-			if rerr != nil {
-				return
-			}
-		case "update":
-			var keyCount int
-			var updateKeyExist bool
-
-			var changes map[string]json.RawMessage
-
-			rerr = json.Unmarshal(rootWebHookObject.Changes, &changes)
-			if rerr != nil {
-				return
-			}
-
-			_, updateKeyExist = changes["updated_at"]
-			keyCount++
-			if keyCount > 1 {
-				return
-			}
-			if !updateKeyExist {
-				return
-			}
-			var updateat time.Time
-			updateat, rerr = time.Parse("2006-01-02 15:04:05 MST", pr.UpdatedAt)
-			if rerr != nil {
-				return
-			}
-
-			repo := &api.GitlabProjectInternal{}
-			repo.Name = rootWebHookObject.Project.Name
-			repo.RefID = projectRefID
-
-			var pr2 = &api.PullRequest{SourceCodePullRequest: &sdk.SourceCodePullRequest{}}
-			pr2.IID = strconv.FormatInt(pr.IID, 10)
-			pr2.RefID = scPr.RefID
-
-			commits, err := ge.FetchPullRequestsCommitsAfter(repo, *pr2, updateat)
-			if err != nil {
-				return fmt.Errorf("error fetching pull requests commits on webhook, err %d", err)
-			}
-
-			sdk.LogDebug(logger, "commits found", "len", len(commits))
-
-			for _, c := range commits {
-				c.IntegrationInstanceID = &integrationInstanceID
-				rerr = pipe.Write(c)
-				if rerr != nil {
-					return
-				}
-			}
-		case "open", "reopen":
-
-			repo := &api.GitlabProjectInternal{}
-			repo.Name = rootWebHookObject.Project.Name
-			repo.RefID = projectRefID
-			var pr2 = &api.PullRequest{SourceCodePullRequest: &sdk.SourceCodePullRequest{}}
-			pr2.IID = strconv.FormatInt(pr.IID, 10)
-			pr2.RefID = scPr.RefID
-
-			commits, err := ge.fetchPullRequestsCommits(repo, *pr2)
-			if err != nil {
-				return fmt.Errorf("error fetching pull requests commits on webhook, err %d", err)
-			}
-
-			sdk.LogDebug(logger, "commits found", "len", len(commits))
-
-			for _, c := range commits {
-				c.IntegrationInstanceID = &integrationInstanceID
-				rerr = pipe.Write(c)
-				if rerr != nil {
-					return
-				}
-			}
-		}
-
-	case "Push Hook":
-		// No need to implement this at this moment
-		// It will fetch commits after a Merge Request event
-	case "Note Hook":
-		note := api.WebhookNote{}
-		rerr = json.Unmarshal(rootWebHookObject.WebHookMainObject, &note)
-		if rerr != nil {
-			return
-		}
-
-		if note.System == false {
-
-			switch note.NoteableType {
-			case "Issue":
-				comment := &sdk.WorkIssueComment{
-					Active:    true,
-					RefID:     fmt.Sprint(note.RefID),
-					RefType:   webhook.RefType(),
-					UserRefID: strconv.FormatInt(note.AuthorID, 10),
-					IssueID:   strconv.FormatInt(rootWebHookObject.Issue.RefID, 10),
-					ProjectID: projectID,
-					Body:      note.Note,
-				}
-				tCreatedAt, _ := time.Parse(api.NoteDateFormat, note.CreatedAt)
-				sdk.ConvertTimeToDateModel(tCreatedAt, &comment.CreatedDate)
-				tUpdatedAt, _ := time.Parse(api.NoteDateFormat, note.UpdatedAt)
-				sdk.ConvertTimeToDateModel(tUpdatedAt, &comment.CreatedDate)
-				rerr = pipe.Write(comment)
-				if rerr != nil {
-					return
-				}
-			case "MergeRequest":
-				var scPr = &sdk.SourceCodePullRequest{}
-				scPr, rerr = rootWebHookObject.MergeRequest.ToSourceCodePullRequest(logger, customerID, repoID, gitlabRefType)
-				if rerr != nil {
-					return
-				}
-				if note.NoteType == "DiffNote" {
-					review := note.ToSourceCodePullRequestReview()
-					review.CustomerID = customerID
-					review.IntegrationInstanceID = sdk.StringPointer(integrationInstanceID)
-					review.PullRequestID = scPr.ID
-					review.RepoID = repoID
-
-					rerr = pipe.Write(review)
-					if rerr != nil {
-						return
-					}
-				} else if note.NoteType == "" {
-					prComment := &sdk.SourceCodePullRequestComment{}
-					prComment.CustomerID = customerID
-					prComment.IntegrationInstanceID = sdk.StringPointer(integrationInstanceID)
-					prComment.PullRequestID = scPr.ID
-
-					prComment.RefType = gitlabRefType
-					prComment.RefID = strconv.FormatInt(note.RefID, 10)
-					prComment.URL = note.URL
-
-					tCreatedAt, _ := time.Parse(api.NoteDateFormat, note.CreatedAt)
-					sdk.ConvertTimeToDateModel(tCreatedAt, &prComment.CreatedDate)
-					tUpdatedAt, _ := time.Parse(api.NoteDateFormat, note.UpdatedAt)
-					sdk.ConvertTimeToDateModel(tUpdatedAt, &prComment.UpdatedDate)
-
-					prComment.RepoID = repoID
-					prComment.Body = note.Note
-
-					prComment.UserRefID = strconv.FormatInt(note.AuthorID, 10)
-
-					rerr = pipe.Write(prComment)
-					if rerr != nil {
-						return
-					}
-
-				}
-			}
-		}
-	case "System Hook":
-		switch rootWebHookObject.EventName {
-		case "repository_update", "project_update", "project_rename":
-			repo, err := api.ProjectByRefID(ge.qc, rootWebHookObject.ProjectID)
-			if err != nil {
-				rerr = err
-				return
-			}
-			repo.IntegrationInstanceID = &integrationInstanceID
-			rerr = pipe.Write(repo)
-			if rerr != nil {
-				return
-			}
-		case "user_create", "user_rename":
-			user, err := api.UserByID(ge.qc, rootWebHookObject.UserID)
-			if err != nil {
-				rerr = err
-				return
-			}
-			user.IntegrationInstanceID = &integrationInstanceID
-			rerr = pipe.Write(user)
-			if rerr != nil {
-				return
-			}
-			// TODO: check if these are useful
-			// case "user_add_to_team":
-			// case "user_update_for_team":
-			// case "user_add_to_group":
-			// case "user_update_for_group":
-			// case "group_create":
-			// case "user_remove_from_team":
-			// case "user_destroy":
-			// case "group_destroy":
-			// case "group_rename":
-			// case "user_remove_from_group":
-		}
-	}
-
-	return
-}
-
-func (i *GitlabIntegration) GetReviewFromAction(
-	logger sdk.Logger,
-	qc api.QueryContext,
-	customerID string,
-	projectName string,
-	projectID string,
-	projectRefID string,
-	prID string,
-	prRefID string,
-	prIID int64,
-	prUpdatedAt string,
-	username string,
-	action string) (review *sdk.SourceCodePullRequestReview, rerr error) {
+	userManager := NewUserManager(customerID, webhook, state, pipe, integrationInstanceID)
 
-	// TODO: iterate over more notes in rare case it is not foudn in the first 20 notes
-	// _, note, err := api.GetGetSinglePullRequestNote(ge.qc, nil, whp.Project.Name, repoRefID, scpr.RefID, wh.IID, whp.User.Username, wh.UpdatedAt, wh.Action)
-	_, note, err := api.GetGetSinglePullRequestNote(qc, nil, projectName, projectRefID, prRefID, prIID, username, prUpdatedAt, action)
+	ge, err := i.SetQueryConfig(i.context(), logger, webhook.Config(), i.manager, customerID)
 	if err != nil {
 		rerr = err
 		return
 	}
 
-	review.CustomerID = customerID
-	review.RefType = gitlabRefType
-	review.RefID = strconv.FormatInt(note.ID, 10)
-	review.RepoID = projectID
-	review.PullRequestID = prID
-	if action == "approved" {
-		review.State = sdk.SourceCodePullRequestReviewStateApproved
-	} else if action == "unapproved" {
-		review.State = sdk.SourceCodePullRequestReviewStateDismissed
-	}
+	ge.pipe = pipe
+	ge.state = state
+	ge.integrationInstanceID = &integrationInstanceID
+	ge.qc.IntegrationInstanceID = integrationInstanceID
+	ge.qc.Pipe = pipe
+	ge.qc.State = state
+	ge.qc.UserManager = userManager
+	ge.qc.WorkManager = NewWorkManager(logger, state)
+	ge.repoProjectManager = NewRepoProjectManager(logger, state, pipe)
 
-	sdk.ConvertTimeToDateModel(note.CreatedAt, &review.CreatedDate)
-	review.UserRefID = strconv.FormatInt(note.Author.ID, 10)
+	for _, user := range payload.users() {
+		rerr = userManager.EmitGitUser(logger, &user)
+		if rerr != nil {
+			sdk.LogError(logger, "err", rerr)
+			return
+		}
+	}
 
-	return
+	return payload.handle(&webhookEvent{
+		ge:                    &ge,
+		logger:                logger,
+		customerID:            customerID,
+		integrationInstanceID: integrationInstanceID,
+		refType:               webhook.RefType(),
+	})
 }
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// gitlab retries the deliveries that fail or time out, the processed ones are kept in state to
+// skip the redeliveries. Each delivery has its own expiring key, and the index of the keys drops
+// the oldest ones past webhookDeliveriesMax so a burst of deliveries doesn't grow the state
+const webhookDeliveryKeyPrefix = "webhook_delivery_"
+
+const webhookDeliveriesKey = "webhook_deliveries"
+
+const webhookDeliveryExpiry = 72 * time.Hour
+
+const webhookDeliveriesMax = 1000
+
+// webhookDeliveryKey is the state key of the processed delivery
+func webhookDeliveryKey(deliveryID string) string {
+	return webhookDeliveryKeyPrefix + deliveryID
+}
+
+type webhookDelivery struct {
+	Event     string    `json:"event"`
+	Processed time.Time `json:"processed"`
+	// ExpiresAt is when the delivery is forgotten, the redeliveries don't extend it
+	ExpiresAt time.Time `json:"expires_at"`
+	// Redeliveries is the number of times the delivery was skipped
+	Redeliveries int `json:"redeliveries"`
+}
+
+// webhookDeliveryID identifies the delivery by the event uuid of gitlab, or the idempotency key of
+// the retries. Older instances send neither and the hash of the body is used instead, so identical
+// bodies collapse into one delivery until it expires, an event repeated with the same payload is
+// skipped. Gitlab doesn't send a date header and the retries wouldn't keep it
+func webhookDeliveryID(headers map[string]string, body []byte) (id string, source string) {
+	if uuid := headers["x-gitlab-event-uuid"]; uuid != "" {
+		return uuid, "event_uuid"
+	}
+	if key := headers["idempotency-key"]; key != "" {
+		return key, "idempotency_key"
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), "body_hash"
+}
+
+// skipRedelivery reports if the delivery was already processed and counts the redelivery
+func skipRedelivery(logger sdk.Logger, state sdk.State, deliveryID string) (bool, error) {
+	var delivery webhookDelivery
+	found, err := state.Get(webhookDeliveryKey(deliveryID), &delivery)
+	if err != nil || !found {
+		return false, err
+	}
+	expires := time.Until(delivery.ExpiresAt)
+	if expires <= 0 {
+		return false, nil
+	}
+	delivery.Redeliveries++
+	sdk.LogInfo(logger, "skipping webhook redelivery", "processed_event", delivery.Event, "redeliveries", delivery.Redeliveries, "since_processed", time.Since(delivery.Processed).String())
+	return true, state.SetWithExpires(webhookDeliveryKey(deliveryID), delivery, expires)
+}
+
+// webhookDeliveriesMu serializes the updates of the index, the deliveries are handled concurrently
+var webhookDeliveriesMu sync.Mutex
+
+// setWebhookDeliveryProcessed records the delivery, the failed ones aren't recorded so gitlab can retry them
+func setWebhookDeliveryProcessed(state sdk.State, deliveryID string, event string) error {
+	now := time.Now()
+	delivery := webhookDelivery{Event: event, Processed: now, ExpiresAt: now.Add(webhookDeliveryExpiry)}
+	if err := state.SetWithExpires(webhookDeliveryKey(deliveryID), delivery, webhookDeliveryExpiry); err != nil {
+		return err
+	}
+	webhookDeliveriesMu.Lock()
+	defer webhookDeliveriesMu.Unlock()
+	var deliveries []string
+	if _, err := state.Get(webhookDeliveriesKey, &deliveries); err != nil {
+		return err
+	}
+	deliveries = append(deliveries, deliveryID)
+	for len(deliveries) > webhookDeliveriesMax {
+		if err := state.Delete(webhookDeliveryKey(deliveries[0])); err != nil {
+			return err
+		}
+		deliveries = deliveries[1:]
+	}
+	return state.SetWithExpires(webhookDeliveriesKey, deliveries, webhookDeliveryExpiry)
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pinpt/gitlab/internal/gitlabtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookRedelivery(t *testing.T) {
+	assert := assert.New(t)
+	g, _, _, instance := newTestIntegration(t)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "merge_request_open.json"))
+	assert.NoError(err)
+	headers := webhookTokenHeader()
+	headers["x-gitlab-event-uuid"] = "9f2c6d1e-5b7a-4c3d-8e9f-0a1b2c3d4e5f"
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, headers)))
+	assert.NotEmpty(instance.Pipe.Written())
+	instance.Pipe.Reset()
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, headers)))
+	assert.Empty(instance.Pipe.Written())
+	var delivery webhookDelivery
+	_, err = instance.State.Get(webhookDeliveryKey(headers["x-gitlab-event-uuid"]), &delivery)
+	assert.NoError(err)
+	assert.Equal("Merge Request Hook", delivery.Event)
+	assert.Equal(1, delivery.Redeliveries)
+	// the retries of the instances without uuid have the same idempotency key
+	headers = webhookTokenHeader()
+	headers["idempotency-key"] = "d41c8e4a-0b6f-4a52-9e11-7f3f0c7b5a10"
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, headers)))
+	assert.NotEmpty(instance.Pipe.Written())
+	instance.Pipe.Reset()
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, headers)))
+	assert.Empty(instance.Pipe.Written())
+	// without either the body identifies the delivery, identical bodies are one delivery
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, webhookTokenHeader())))
+	assert.NotEmpty(instance.Pipe.Written())
+	instance.Pipe.Reset()
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, webhookTokenHeader())))
+	assert.Empty(instance.Pipe.Written())
+}
+
+func TestWebHookRedeliveryExpiry(t *testing.T) {
+	assert := assert.New(t)
+	instance := gitlabtest.NewInstance("")
+	assert.NoError(setWebhookDeliveryProcessed(instance.State, "1", "Push Hook"))
+	var processed webhookDelivery
+	_, err := instance.State.Get(webhookDeliveryKey("1"), &processed)
+	assert.NoError(err)
+	// the redeliveries don't extend the expiry
+	skip, err := skipRedelivery(instance.Logger, instance.State, "1")
+	assert.NoError(err)
+	assert.True(skip)
+	var delivery webhookDelivery
+	_, err = instance.State.Get(webhookDeliveryKey("1"), &delivery)
+	assert.NoError(err)
+	assert.True(processed.ExpiresAt.Equal(delivery.ExpiresAt))
+	delivery.ExpiresAt = time.Now()
+	assert.NoError(instance.State.Set(webhookDeliveryKey("1"), delivery))
+	skip, err = skipRedelivery(instance.Logger, instance.State, "1")
+	assert.NoError(err)
+	assert.False(skip)
+}
+
+func TestWebHookDeliveriesMax(t *testing.T) {
+	assert := assert.New(t)
+	instance := gitlabtest.NewInstance("")
+	for i := 0; i <= webhookDeliveriesMax; i++ {
+		assert.NoError(setWebhookDeliveryProcessed(instance.State, strconv.Itoa(i), "Push Hook"))
+	}
+	// the oldest delivery is dropped past the max
+	assert.False(instance.State.Exists(webhookDeliveryKey("0")))
+	assert.True(instance.State.Exists(webhookDeliveryKey("1")))
+	assert.True(instance.State.Exists(webhookDeliveryKey(strconv.Itoa(webhookDeliveriesMax))))
+}
+
+func TestWebHookDeliveriesConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	instance := gitlabtest.NewInstance("")
+	var wg sync.WaitGroup
+	for i := 0; i < 500; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(setWebhookDeliveryProcessed(instance.State, strconv.Itoa(i), "Push Hook"))
+		}(i)
+	}
+	wg.Wait()
+	// no delivery is lost from the index
+	var deliveries []string
+	_, err := instance.State.Get(webhookDeliveriesKey, &deliveries)
+	assert.NoError(err)
+	assert.Len(deliveries, 500)
+}
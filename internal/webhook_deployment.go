@@ -0,0 +1,17 @@
+package internal
+
+import "github.com/pinpt/gitlab/internal/api"
+
+// deploymentHookPayload is the payload of the Deployment Hook event
+type deploymentHookPayload struct {
+	webHookCommon
+	DeploymentID int64  `json:"deployment_id"`
+	Environment  string `json:"environment"`
+	// EnvironmentTier older instances don't have it
+	EnvironmentTier string `json:"environment_tier"`
+}
+
+func (p *deploymentHookPayload) handle(ev *webhookEvent) error {
+	environment := &api.Environment{Name: p.Environment, Tier: p.EnvironmentTier}
+	return ev.ge.exportDeployment(p.Project.toProject(ev.customerID), p.DeploymentID, environment)
+}
@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookDeployment(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "deployment.json"))
+	assert.NoError(err)
+	assert.NoError(g.WebHook(instance.WebHook("Deployment Hook", body, webhookTokenHeader())))
+	assertNoMissingFixtures(t, server)
+	var deployments []string
+	for _, m := range instance.Pipe.Written() {
+		if deployment, ok := m.(*sdk.CICDDeployment); ok {
+			deployments = append(deployments, deployment.RefID)
+			assert.Equal("acme/widgets", deployment.RepoName)
+			assert.Equal(sdk.CICDDeploymentStatusPass, deployment.Status)
+		}
+	}
+	assert.Equal([]string{"800"}, deployments)
+	// the environment is the payload's, the environments aren't listed
+	for _, r := range server.Requests() {
+		assert.NotEqual("projects/100/environments", r.Path)
+	}
+}
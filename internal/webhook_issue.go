@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+)
+
+// issueHookPayload is the payload of the Issue Hook event
+type issueHookPayload struct {
+	webHookCommon
+	ObjectAttributes api.IssueWebHook `json:"object_attributes"`
+}
+
+func (p *issueHookPayload) handle(ev *webhookEvent) error {
+	ge := ev.ge
+	sdk.LogInfo(ev.logger, "recovering work manager state")
+	if err := ge.qc.WorkManager.Restore(); err != nil {
+		sdk.LogError(ev.logger, "error recovering work manager state", "err", err)
+		return err
+	}
+	if err := ge.writeSingleIssue(p.Project.toProject(ev.customerID), p.ObjectAttributes.IID); err != nil {
+		return err
+	}
+	sdk.LogInfo(ev.logger, "persisting work manager into state")
+	if err := ge.qc.WorkManager.Persist(); err != nil {
+		sdk.LogError(ev.logger, "error persisting work manager state", "err", err)
+		return err
+	}
+	return nil
+}
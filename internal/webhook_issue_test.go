@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookIssueProjectDisplayName(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "issue_update.json"))
+	assert.NoError(err)
+	// the display name of the project differs from its path, the api is called with the project id
+	assert.NoError(g.WebHook(instance.WebHook("Issue Hook", body, webhookTokenHeader())))
+	var fetched bool
+	for _, r := range server.Requests() {
+		if r.Path == "projects/100/issues" {
+			fetched = true
+			assert.Equal("1", r.Query.Get("iids[]"))
+		}
+	}
+	assert.True(fetched)
+	var issues []string
+	for _, m := range instance.Pipe.Written() {
+		if issue, ok := m.(*sdk.WorkIssue); ok {
+			issues = append(issues, issue.RefID)
+		}
+	}
+	assert.Equal([]string{"5001"}, issues)
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+)
+
+// mergeRequestHookPayload is the payload of the Merge Request Hook event
+type mergeRequestHookPayload struct {
+	webHookCommon
+	ObjectAttributes api.WebhookPullRequest     `json:"object_attributes"`
+	Changes          map[string]json.RawMessage `json:"changes"`
+	Reviewers        []api.Reviewer             `json:"reviewers"`
+}
+
+func (p *mergeRequestHookPayload) handle(ev *webhookEvent) error {
+	ge := ev.ge
+	pr := &p.ObjectAttributes
+	repo := p.Project.toProject(ev.customerID)
+
+	scPr, err := pr.ToSourceCodePullRequest(ev.logger, ev.customerID, repo.ID, gitlabRefType)
+	if err != nil {
+		return err
+	}
+	scPr.IntegrationInstanceID = &ev.integrationInstanceID
+
+	switch scPr.Status {
+	case sdk.SourceCodePullRequestStatusClosed:
+		scPr.ClosedByRefID = p.User.RefID(ev.customerID)
+	case sdk.SourceCodePullRequestStatusMerged:
+		scPr.MergedByRefID = p.User.RefID(ev.customerID)
+	}
+
+	prr := api.PullRequest{}
+	prr.SourceCodePullRequest = scPr
+	prr.IID = strconv.FormatInt(pr.IID, 10)
+	// the reviewers are emitted like in the export, they are gitlab users without email
+	for i := range p.Reviewers {
+		reviewer := &p.Reviewers[i]
+		if err := ge.qc.UserManager.EmitGitUser(ev.logger, reviewer); err != nil {
+			return err
+		}
+		prr.ReviewerRefIDs = append(prr.ReviewerRefIDs, reviewer.RefID(ev.customerID))
+	}
+	if err := ge.exportPullRequestsReviews(repo, prr); err != nil {
+		return err
+	}
+
+	sdk.LogDebug(ev.logger, "source code pull request", "body", scPr.Stringify())
+
+	if err := ge.pipe.Write(scPr); err != nil {
+		return err
+	}
+
+	var pr2 = &api.PullRequest{SourceCodePullRequest: &sdk.SourceCodePullRequest{}}
+	pr2.IID = strconv.FormatInt(pr.IID, 10)
+	pr2.RefID = scPr.RefID
+
+	var commits []*sdk.SourceCodePullRequestCommit
+	switch pr.Action {
+	case "update":
+		// only the updates of the commits change updated_at
+		if _, ok := p.Changes["updated_at"]; !ok {
+			return nil
+		}
+		updatedAt, err := time.Parse("2006-01-02 15:04:05 MST", pr.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		commits, err = ge.FetchPullRequestsCommitsAfter(repo, *pr2, updatedAt)
+		if err != nil {
+			return fmt.Errorf("error fetching pull requests commits on webhook, err %w", err)
+		}
+	case "open", "reopen":
+		commits, err = ge.fetchPullRequestsCommits(repo, *pr2)
+		if err != nil {
+			return fmt.Errorf("error fetching pull requests commits on webhook, err %w", err)
+		}
+	}
+
+	sdk.LogDebug(ev.logger, "commits found", "len", len(commits))
+
+	for _, c := range commits {
+		c.IntegrationInstanceID = &ev.integrationInstanceID
+		if err := ge.pipe.Write(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/gitlabtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookMergeRequestOpen(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "merge_request_open.json"))
+	assert.NoError(err)
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, webhookTokenHeader())))
+	assertNoMissingFixtures(t, server)
+	for _, m := range instance.Pipe.Written() {
+		if request, ok := m.(*sdk.SourceCodePullRequestReviewRequest); ok {
+			assert.Equal(gitlabtest.IntegrationInstanceID, *request.IntegrationInstanceID)
+		}
+	}
+	gitlabtest.AssertGolden(t, filepath.Join("testdata", "golden", "webhook_merge_request_open.json"), gitlabtest.Records(instance.Pipe.Written()))
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+)
+
+// noteHookPayload is the payload of the Note Hook event
+type noteHookPayload struct {
+	webHookCommon
+	ObjectAttributes api.WebhookNote        `json:"object_attributes"`
+	MergeRequest     api.WebhookPullRequest `json:"merge_request"`
+	Issue            struct {
+		RefID int64 `json:"id"`
+	} `json:"issue"`
+}
+
+func (p *noteHookPayload) handle(ev *webhookEvent) error {
+	if p.ObjectAttributes.System {
+		return nil
+	}
+	switch p.ObjectAttributes.NoteableType {
+	case "Issue":
+		return p.handleIssueNote(ev)
+	case "MergeRequest":
+		return p.handleMergeRequestNote(ev)
+	}
+	return nil
+}
+
+func (p *noteHookPayload) handleIssueNote(ev *webhookEvent) error {
+	note := &p.ObjectAttributes
+	comment := &sdk.WorkIssueComment{
+		Active:    true,
+		RefID:     fmt.Sprint(note.RefID),
+		RefType:   ev.refType,
+		UserRefID: strconv.FormatInt(note.AuthorID, 10),
+		IssueID:   strconv.FormatInt(p.Issue.RefID, 10),
+		ProjectID: sdk.NewWorkProjectID(ev.customerID, strconv.FormatInt(p.Project.ID, 10), gitlabRefType),
+		Body:      note.Note,
+	}
+	tCreatedAt, _ := time.Parse(api.NoteDateFormat, note.CreatedAt)
+	sdk.ConvertTimeToDateModel(tCreatedAt, &comment.CreatedDate)
+	tUpdatedAt, _ := time.Parse(api.NoteDateFormat, note.UpdatedAt)
+	sdk.ConvertTimeToDateModel(tUpdatedAt, &comment.CreatedDate)
+	return ev.ge.pipe.Write(comment)
+}
+
+func (p *noteHookPayload) handleMergeRequestNote(ev *webhookEvent) error {
+	note := &p.ObjectAttributes
+	repoID := sdk.NewSourceCodeRepoID(ev.customerID, strconv.FormatInt(p.Project.ID, 10), gitlabRefType)
+	scPr, err := p.MergeRequest.ToSourceCodePullRequest(ev.logger, ev.customerID, repoID, gitlabRefType)
+	if err != nil {
+		return err
+	}
+	pullRequestID := sdk.NewSourceCodePullRequestID(ev.customerID, scPr.RefID, gitlabRefType, repoID)
+	prComment := &sdk.SourceCodePullRequestComment{}
+	prComment.CustomerID = ev.customerID
+	prComment.IntegrationInstanceID = sdk.StringPointer(ev.integrationInstanceID)
+	prComment.PullRequestID = pullRequestID
+
+	prComment.RefType = gitlabRefType
+	prComment.RefID = strconv.FormatInt(note.RefID, 10)
+	prComment.URL = note.URL
+
+	tCreatedAt, _ := time.Parse(api.NoteDateFormat, note.CreatedAt)
+	sdk.ConvertTimeToDateModel(tCreatedAt, &prComment.CreatedDate)
+	tUpdatedAt, _ := time.Parse(api.NoteDateFormat, note.UpdatedAt)
+	sdk.ConvertTimeToDateModel(tUpdatedAt, &prComment.UpdatedDate)
+
+	prComment.RepoID = repoID
+	prComment.Body = note.Note
+
+	prComment.UserRefID = strconv.FormatInt(note.AuthorID, 10)
+
+	return ev.ge.pipe.Write(prComment)
+}
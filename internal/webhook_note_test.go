@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/gitlabtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookDiffNote(t *testing.T) {
+	assert := assert.New(t)
+	g, _, _, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	instance.Pipe.Reset()
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "note_diff.json"))
+	assert.NoError(err)
+	assert.NoError(g.WebHook(instance.WebHook("Note Hook", body, webhookTokenHeader())))
+	repoID := sdk.NewSourceCodeRepoID(gitlabtest.CustomerID, "100", gitlabRefType)
+	pullRequestID := sdk.NewSourceCodePullRequestID(gitlabtest.CustomerID, "2001", gitlabRefType, repoID)
+	var comments int
+	for _, m := range instance.Pipe.Written() {
+		if comment, ok := m.(*sdk.SourceCodePullRequestComment); ok {
+			comments++
+			assert.Equal("3005", comment.RefID)
+			assert.Equal(pullRequestID, comment.PullRequestID)
+		}
+		_, review := m.(*sdk.SourceCodePullRequestReview)
+		assert.False(review)
+	}
+	assert.Equal(1, comments)
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+)
+
+// pipelineHookPayload is the payload of the Pipeline Hook event
+type pipelineHookPayload struct {
+	webHookCommon
+	ObjectAttributes struct {
+		ID int64 `json:"id"`
+	} `json:"object_attributes"`
+}
+
+func (p *pipelineHookPayload) handle(ev *webhookEvent) error {
+	return ev.ge.exportPipeline(p.Project.toProject(ev.customerID), p.ObjectAttributes.ID)
+}
+
+// jobHookPayload is the payload of the Job Hook event, the commit is the pipeline of the job
+type jobHookPayload struct {
+	webHookCommon
+	Sha             string `json:"sha"`
+	BuildID         int64  `json:"build_id"`
+	BuildName       string `json:"build_name"`
+	BuildStatus     string `json:"build_status"`
+	BuildCreatedAt  string `json:"build_created_at"`
+	BuildStartedAt  string `json:"build_started_at"`
+	BuildFinishedAt string `json:"build_finished_at"`
+	PipelineID      int64  `json:"pipeline_id"`
+	Commit          struct {
+		Status string `json:"status"`
+	} `json:"commit"`
+}
+
+// toJob returns the job of the payload, the dates are null until the job starts and finishes
+func (p *jobHookPayload) toJob() *api.Job {
+	job := &api.Job{
+		ID:     p.BuildID,
+		Name:   p.BuildName,
+		Status: p.BuildStatus,
+		WebURL: sdk.JoinURL(p.Project.WebURL, "-", "jobs", strconv.FormatInt(p.BuildID, 10)),
+	}
+	job.Commit.ID = p.Sha
+	job.CreatedAt, _ = time.Parse(api.NoteDateFormat, p.BuildCreatedAt)
+	if t, err := time.Parse(api.NoteDateFormat, p.BuildStartedAt); err == nil {
+		job.StartedAt = &t
+	}
+	if t, err := time.Parse(api.NoteDateFormat, p.BuildFinishedAt); err == nil {
+		job.FinishedAt = &t
+	}
+	return job
+}
+
+// handle writes the job of the payload, the pipeline is only fetched again when its status changed
+func (p *jobHookPayload) handle(ev *webhookEvent) error {
+	repo := p.Project.toProject(ev.customerID)
+	if err := ev.ge.writeJobBuild(repo, p.toJob()); err != nil {
+		return err
+	}
+	changed, err := ev.ge.pipelineStatusChanged(p.PipelineID, p.Commit.Status)
+	if err != nil || !changed {
+		return err
+	}
+	return ev.ge.exportPipelineBuild(repo, p.PipelineID)
+}
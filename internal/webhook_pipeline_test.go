@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookPipeline(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "pipeline.json"))
+	assert.NoError(err)
+	assert.NoError(g.WebHook(instance.WebHook("Pipeline Hook", body, webhookTokenHeader())))
+	assertNoMissingFixtures(t, server)
+	builds := make(map[string]sdk.CICDBuildStatus)
+	for _, m := range instance.Pipe.Written() {
+		if build, ok := m.(*sdk.CICDBuild); ok {
+			builds[build.RefID] = build.Status
+			assert.Equal("acme/widgets", build.RepoName)
+			assert.Equal("9f8e7d6c5b4a39281706f5e4d3c2b1a098765432", build.CommitSha)
+		}
+	}
+	assert.Equal(map[string]sdk.CICDBuildStatus{
+		"701":      sdk.CICDBuildStatusFail,
+		"job-7010": sdk.CICDBuildStatusPass,
+		"job-7011": sdk.CICDBuildStatusFail,
+	}, builds)
+}
+
+func TestWebHookJob(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "job.json"))
+	assert.NoError(err)
+	writtenBuilds := func() map[string]*sdk.CICDBuild {
+		builds := make(map[string]*sdk.CICDBuild)
+		for _, m := range instance.Pipe.Written() {
+			if build, ok := m.(*sdk.CICDBuild); ok {
+				builds[build.RefID] = build
+			}
+		}
+		return builds
+	}
+	// the job is taken from the payload, the pipeline isn't known yet so it's fetched
+	assert.NoError(g.WebHook(instance.WebHook("Job Hook", body, webhookTokenHeader())))
+	assertNoMissingFixtures(t, server)
+	builds := writtenBuilds()
+	assert.Len(builds, 2)
+	if job, ok := builds["job-7011"]; assert.True(ok) {
+		assert.Equal(sdk.CICDBuildStatusFail, job.Status)
+		assert.Equal("9f8e7d6c5b4a39281706f5e4d3c2b1a098765432", job.CommitSha)
+		assert.Equal("http://gitlab.acme.test/acme/widgets/-/jobs/7011", job.URL)
+		assert.Equal("2020-11-20T09:20:00Z", sdk.DateFromEpoch(job.EndDate.Epoch).UTC().Format(time.RFC3339))
+	}
+	assert.Contains(builds, "701")
+	for _, r := range server.Requests() {
+		assert.NotEqual("projects/100/pipelines/701/jobs", r.Path)
+	}
+	// the status of the pipeline didn't change, only the job is written
+	instance.Pipe.Reset()
+	headers := webhookTokenHeader()
+	headers["x-gitlab-event-uuid"] = "2"
+	assert.NoError(g.WebHook(instance.WebHook("Job Hook", body, headers)))
+	builds = writtenBuilds()
+	assert.Len(builds, 1)
+	assert.Contains(builds, "job-7011")
+}
@@ -0,0 +1,13 @@
+package internal
+
+import "github.com/pinpt/gitlab/internal/api"
+
+// pushHookPayload is the payload of the Push Hook event
+type pushHookPayload struct {
+	webHookCommon
+	api.WebhookPush
+}
+
+func (p *pushHookPayload) handle(ev *webhookEvent) error {
+	return ev.ge.handlePush(p.Project.toProject(ev.customerID), &p.WebhookPush)
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/gitlabtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookPush(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "push.json"))
+	assert.NoError(err)
+	assert.NoError(g.WebHook(instance.WebHook("Push Hook", body, webhookTokenHeader())))
+	// the commits are in the payload, the api isn't called
+	assert.Empty(server.Requests())
+	gitlabtest.AssertGolden(t, filepath.Join("testdata", "golden", "webhook_push.json"), gitlabtest.Records(instance.Pipe.Written()))
+
+	// the deleted branch has no commits
+	body, err = ioutil.ReadFile(filepath.Join("testdata", "webhooks", "push_delete.json"))
+	assert.NoError(err)
+	instance.Pipe.Reset()
+	assert.NoError(g.WebHook(instance.WebHook("Push Hook", body, webhookTokenHeader())))
+	assert.Empty(instance.Pipe.Written())
+}
+
+func TestWebHookPushTruncated(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "push_truncated.json"))
+	assert.NoError(err)
+	assert.NoError(g.WebHook(instance.WebHook("Push Hook", body, webhookTokenHeader())))
+	assertNoMissingFixtures(t, server)
+	var compare *gitlabtest.Request
+	for _, r := range server.Requests() {
+		if r.Path == "projects/100/repository/compare" {
+			r := r
+			compare = &r
+		}
+	}
+	// the branch is new, the commits are compared against the default branch
+	if assert.NotNil(compare) {
+		assert.Equal("main", compare.Query.Get("from"))
+		assert.Equal("0000000000000000000000000000000c0ffee014", compare.Query.Get("to"))
+	}
+	var commits int
+	for _, m := range instance.Pipe.Written() {
+		if _, ok := m.(*sdk.SourceCodeCommit); ok {
+			commits++
+		}
+	}
+	assert.Equal(21, commits)
+}
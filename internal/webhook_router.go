@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+const webhookUnknownEventsKey = "webhook_unknown_events"
+
+// webhookUnknownEventsExpiry is how long the counts are kept without an unknown event
+const webhookUnknownEventsExpiry = 30 * 24 * time.Hour
+
+// webhookEvent is a webhook delivery being handled
+type webhookEvent struct {
+	ge                    *GitlabExport
+	logger                sdk.Logger
+	customerID            string
+	integrationInstanceID string
+	refType               string
+}
+
+// webhookPayload is the body of a webhook event, decoded into the type of the event
+type webhookPayload interface {
+	// users returns the users of the payload, they are emitted before the event is handled
+	users() []user
+	// handle exports what the event changed
+	handle(ev *webhookEvent) error
+}
+
+// webHookCommon are the fields shared by the project events
+type webHookCommon struct {
+	Project   webHookProject `json:"project"`
+	User      user           `json:"user"`
+	Assignees []user         `json:"assignees"`
+}
+
+func (c *webHookCommon) users() []user {
+	return append([]user{c.User}, c.Assignees...)
+}
+
+// webhookRoutes maps the X-Gitlab-Event header to the payload of the event, a new event
+// is handled by adding its payload here
+var webhookRoutes = map[string]func() webhookPayload{
+	"Issue Hook":         func() webhookPayload { return &issueHookPayload{} },
+	"Merge Request Hook": func() webhookPayload { return &mergeRequestHookPayload{} },
+	"Note Hook":          func() webhookPayload { return &noteHookPayload{} },
+	"Push Hook":          func() webhookPayload { return &pushHookPayload{} },
+	"Pipeline Hook":      func() webhookPayload { return &pipelineHookPayload{} },
+	"Job Hook":           func() webhookPayload { return &jobHookPayload{} },
+	"Deployment Hook":    func() webhookPayload { return &deploymentHookPayload{} },
+}
+
+// systemHookRoutes maps the event_name of the system hooks to the payload of the event
+var systemHookRoutes = map[string]func() webhookPayload{
+	"repository_update": func() webhookPayload { return &projectSystemHookPayload{} },
+	"project_update":    func() webhookPayload { return &projectSystemHookPayload{} },
+	"project_rename":    func() webhookPayload { return &projectSystemHookPayload{} },
+	"user_create":       func() webhookPayload { return &userSystemHookPayload{} },
+	"user_rename":       func() webhookPayload { return &userSystemHookPayload{} },
+	"project_destroy":   func() webhookPayload { return &projectDestroySystemHookPayload{} },
+	"user_destroy":      func() webhookPayload { return &userDestroySystemHookPayload{} },
+	"group_destroy":     func() webhookPayload { return &groupDestroySystemHookPayload{} },
+	"group_rename":      func() webhookPayload { return &groupRenameSystemHookPayload{} },
+
+	"user_add_to_group":      func() webhookPayload { return &groupMemberSystemHookPayload{} },
+	"user_update_for_group":  func() webhookPayload { return &groupMemberSystemHookPayload{} },
+	"user_remove_from_group": func() webhookPayload { return &groupMemberSystemHookPayload{} },
+	"user_add_to_team":       func() webhookPayload { return &teamMemberSystemHookPayload{} },
+	"user_update_for_team":   func() webhookPayload { return &teamMemberSystemHookPayload{} },
+	"user_remove_from_team":  func() webhookPayload { return &teamMemberSystemHookPayload{} },
+}
+
+// webhookKinds maps the object_kind of the payloads to their event, for the deliveries
+// without the X-Gitlab-Event header
+var webhookKinds = map[string]string{
+	"issue":         "Issue Hook",
+	"merge_request": "Merge Request Hook",
+	"note":          "Note Hook",
+	"push":          "Push Hook",
+	"pipeline":      "Pipeline Hook",
+	"build":         "Job Hook",
+	"deployment":    "Deployment Hook",
+}
+
+// webhookRoute returns the event of a delivery and an empty payload to decode it, the payload
+// is nil when the event has no handler
+func webhookRoute(event string, body []byte) (string, webhookPayload) {
+	var kind struct {
+		ObjectKind string `json:"object_kind"`
+		EventName  string `json:"event_name"`
+	}
+	// the errors are reported when the payload is decoded
+	json.Unmarshal(body, &kind)
+	if event == "" {
+		event = webhookKinds[kind.ObjectKind]
+		if event == "" {
+			event = kind.ObjectKind
+		}
+	}
+	newPayload, ok := webhookRoutes[event]
+	if event == "System Hook" {
+		newPayload, ok = systemHookRoutes[kind.EventName]
+		event += "/" + kind.EventName
+	}
+	if !ok {
+		return event, nil
+	}
+	return event, newPayload()
+}
+
+// countUnknownWebhookEvent counts the deliveries of an event without a handler
+func countUnknownWebhookEvent(state sdk.State, event string) error {
+	counts := make(map[string]int)
+	if _, err := state.Get(webhookUnknownEventsKey, &counts); err != nil {
+		return err
+	}
+	counts[event]++
+	return state.SetWithExpires(webhookUnknownEventsKey, counts, webhookUnknownEventsExpiry)
+}
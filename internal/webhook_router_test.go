@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookRoute(t *testing.T) {
+	assert := assert.New(t)
+	event, payload := webhookRoute("Note Hook", []byte(`{"object_kind":"note"}`))
+	assert.Equal("Note Hook", event)
+	assert.IsType(&noteHookPayload{}, payload)
+	// the object kind routes the deliveries without the header
+	event, payload = webhookRoute("", []byte(`{"object_kind":"build"}`))
+	assert.Equal("Job Hook", event)
+	assert.IsType(&jobHookPayload{}, payload)
+	event, payload = webhookRoute("System Hook", []byte(`{"event_name":"user_rename"}`))
+	assert.Equal("System Hook/user_rename", event)
+	assert.IsType(&userSystemHookPayload{}, payload)
+	event, payload = webhookRoute("Wiki Page Hook", []byte(`{"object_kind":"wiki_page"}`))
+	assert.Equal("Wiki Page Hook", event)
+	assert.Nil(payload)
+}
+
+func TestWebHookUnknownEvent(t *testing.T) {
+	assert := assert.New(t)
+	g, _, _, instance := newTestIntegration(t)
+	assert.NoError(g.WebHook(instance.WebHook("Wiki Page Hook", []byte(`{"object_kind":"wiki_page"}`), webhookTokenHeader())))
+	assert.NoError(g.WebHook(instance.WebHook("Wiki Page Hook", []byte(`{"object_kind":"wiki_page","title":"b"}`), webhookTokenHeader())))
+	assert.NoError(g.WebHook(instance.WebHook("System Hook", []byte(`{"event_name":"key_create"}`), webhookTokenHeader())))
+	assert.Empty(instance.Pipe.Written())
+	counts := make(map[string]int)
+	_, err := instance.State.Get(webhookUnknownEventsKey, &counts)
+	assert.NoError(err)
+	assert.Equal(map[string]int{"Wiki Page Hook": 2, "System Hook/key_create": 1}, counts)
+}
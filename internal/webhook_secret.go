@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+)
+
+// tokenHookVersion is the first hook version registered with the secret of the webhook manager as
+// token. The hooks of older versions send no token until an export recreates them
+const tokenHookVersion = 6
+
+// errInvalidWebhookToken is returned for deliveries without the secret of the webhook manager
+var errInvalidWebhookToken = errors.New("invalid webhook token")
+
+// verifyWebhookToken checks the X-Gitlab-Token of a delivery against the secret. Only the deliveries
+// to the url of a hook older than tokenHookVersion can come without token, the secret isn't kept in
+// state so losing the state doesn't reject the registered hooks
+func verifyWebhookToken(secret string, hookURL string, token string) error {
+	if token == "" {
+		if legacyHookURL(hookURL) {
+			return nil
+		}
+		return errInvalidWebhookToken
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return errInvalidWebhookToken
+	}
+	return nil
+}
+
+// legacyHookURL reports if the hook url has a version older than tokenHookVersion
+func legacyHookURL(hookURL string) bool {
+	u, err := url.Parse(hookURL)
+	if err != nil {
+		return false
+	}
+	version, err := strconv.Atoi(u.Query().Get("version"))
+	return err == nil && version < tokenHookVersion
+}
+
+// gitlab doesn't return the token of the hooks, the hash of the token each hook was registered with
+// is kept to update the hooks when the secret changes. A missing hash updates the hook again
+const webhookTokenKeyPrefix = "webhook_token_"
+
+const webhookTokenExpiry = 30 * 24 * time.Hour
+
+func webhookTokenKey(whType sdk.WebHookScope, entityID string) string {
+	return webhookTokenKeyPrefix + string(whType) + "_" + entityID
+}
+
+func webhookTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// webhookTokenChanged reports if the hook wasn't registered with the current secret
+func (wr *webHookRegistration) webhookTokenChanged(whType sdk.WebHookScope, entityID string) (bool, error) {
+	var hash string
+	found, err := wr.ge.state.Get(webhookTokenKey(whType, entityID), &hash)
+	if err != nil {
+		return false, err
+	}
+	return !found || hash != webhookTokenHash(wr.secret), nil
+}
+
+// setWebhookToken records that the hook has the current secret
+func (wr *webHookRegistration) setWebhookToken(whType sdk.WebHookScope, entityID string) error {
+	return wr.ge.state.SetWithExpires(webhookTokenKey(whType, entityID), webhookTokenHash(wr.secret), webhookTokenExpiry)
+}
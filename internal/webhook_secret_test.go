@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/gitlab/internal/gitlabtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// hookTokens returns the tokens sent to gitlab by the hook requests made since the request before
+func hookTokens(t *testing.T, server *gitlabtest.Server, before int) []string {
+	var tokens []string
+	for _, r := range server.Requests()[before:] {
+		if (r.Method == "POST" || r.Method == "PUT") && strings.HasPrefix(r.Path, "groups/10/hooks") {
+			var hook map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(r.Body), &hook))
+			tokens = append(tokens, r.Method+" "+hook["token"].(string))
+			// the secret isn't sent in the logged query
+			assert.Empty(t, r.Query.Get("token"))
+		}
+	}
+	return tokens
+}
+
+func TestWebHookToken(t *testing.T) {
+	assert := assert.New(t)
+	g, server, manager, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	assert.Equal([]string{"POST " + gitlabtest.WebHookSecret}, hookTokens(t, server, 0))
+	hookURL := manager.WebHooks.Hooks()[0]
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "merge_request_open.json"))
+	assert.NoError(err)
+	instance.Pipe.Reset()
+	assert.Equal(errInvalidWebhookToken, g.WebHook(instance.WebHook("Merge Request Hook", body, nil).WithURL(hookURL)))
+	assert.Equal(errInvalidWebhookToken, g.WebHook(instance.WebHook("Merge Request Hook", body, map[string]string{"x-gitlab-token": ""}).WithURL(hookURL)))
+	assert.Equal(errInvalidWebhookToken, g.WebHook(instance.WebHook("Merge Request Hook", body, map[string]string{"x-gitlab-token": "guess"}).WithURL(hookURL)))
+	assert.Empty(instance.Pipe.Written())
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, webhookTokenHeader()).WithURL(hookURL)))
+	assert.NotEmpty(instance.Pipe.Written())
+}
+
+func TestWebHookTokenLegacyHook(t *testing.T) {
+	assert := assert.New(t)
+	g, _, _, instance := newTestIntegration(t)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "merge_request_open.json"))
+	assert.NoError(err)
+	// the hooks registered before the tokens send none until an export recreates them
+	legacyURL := gitlabtest.WebHookURL + "?integration_instance_id=" + gitlabtest.IntegrationInstanceID + "&version=5"
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, nil).WithURL(legacyURL)))
+	assert.Equal(errInvalidWebhookToken, g.WebHook(instance.WebHook("Merge Request Hook", body, map[string]string{"x-gitlab-token": "guess"}).WithURL(legacyURL)))
+	// the hooks without version are rejected
+	assert.Equal(errInvalidWebhookToken, g.WebHook(instance.WebHook("Merge Request Hook", body, nil)))
+}
+
+func TestWebHookTokenStateLoss(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	hooks := server.Requests()
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "merge_request_open.json"))
+	assert.NoError(err)
+	instance.State = gitlabtest.NewState()
+	// the registered hooks are still accepted
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, webhookTokenHeader())))
+	assert.NotEmpty(instance.Pipe.Written())
+	// the next export doesn't know the token of the hook and sets it again
+	server.Respond("GET", "groups/10/hooks", 200, hooksResponse(t, server, len(hooks)))
+	before := len(server.Requests())
+	assert.NoError(g.Export(instance.Export(false)))
+	assert.Equal([]string{"PUT " + gitlabtest.WebHookSecret}, hookTokens(t, server, before))
+	before = len(server.Requests())
+	assert.NoError(g.Export(instance.Export(false)))
+	assert.Empty(hookTokens(t, server, before))
+}
+
+func TestWebHookTokenRotation(t *testing.T) {
+	assert := assert.New(t)
+	g, server, manager, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	server.Respond("GET", "groups/10/hooks", 200, hooksResponse(t, server, len(server.Requests())))
+	manager.WebHooks.SetSecret("rotated-secret")
+	before := len(server.Requests())
+	assert.NoError(g.Export(instance.Export(false)))
+	assert.Equal([]string{"PUT rotated-secret"}, hookTokens(t, server, before))
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "webhooks", "merge_request_open.json"))
+	assert.NoError(err)
+	assert.Equal(errInvalidWebhookToken, g.WebHook(instance.WebHook("Merge Request Hook", body, webhookTokenHeader())))
+	assert.NoError(g.WebHook(instance.WebHook("Merge Request Hook", body, map[string]string{"x-gitlab-token": "rotated-secret"})))
+	// the rotated hook isn't errored
+	assert.Empty(manager.WebHooks.Errors())
+}
+
+// hooksResponse returns the group hooks gitlab has after the hook created by the requests made before
+func hooksResponse(t *testing.T, server *gitlabtest.Server, before int) string {
+	for _, r := range server.Requests()[:before] {
+		if r.Method == "POST" && r.Path == "groups/10/hooks" {
+			var hook map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(r.Body), &hook))
+			hook["id"] = 1
+			hook["alert_status"] = "executable"
+			delete(hook, "token")
+			buf, err := json.Marshal([]interface{}{hook})
+			assert.NoError(t, err)
+			return string(buf)
+		}
+	}
+	t.Fatal("no hook created")
+	return ""
+}
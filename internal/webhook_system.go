@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+)
+
+// systemHookPayload are the fields shared by the system hook events, they have no user object
+type systemHookPayload struct {
+	EventName string `json:"event_name"`
+}
+
+func (p *systemHookPayload) users() []user {
+	return nil
+}
+
+// projectSystemHookPayload is the payload of the system hook events changing a project
+type projectSystemHookPayload struct {
+	systemHookPayload
+	ProjectID int64 `json:"project_id"`
+}
+
+func (p *projectSystemHookPayload) handle(ev *webhookEvent) error {
+	repo, err := api.ProjectByRefID(ev.ge.qc, p.ProjectID)
+	if err != nil {
+		return err
+	}
+	repo.IntegrationInstanceID = &ev.integrationInstanceID
+	return ev.ge.pipe.Write(repo)
+}
+
+// userSystemHookPayload is the payload of the system hook events changing a user
+type userSystemHookPayload struct {
+	systemHookPayload
+	UserID int64 `json:"user_id"`
+}
+
+func (p *userSystemHookPayload) handle(ev *webhookEvent) error {
+	user, err := api.UserByID(ev.ge.qc, p.UserID)
+	if err != nil {
+		return err
+	}
+	user.IntegrationInstanceID = &ev.integrationInstanceID
+	return ev.ge.pipe.Write(user)
+}
+
+// projectDestroySystemHookPayload is the payload of the project_destroy system hook event
+type projectDestroySystemHookPayload struct {
+	systemHookPayload
+	ProjectID         int64  `json:"project_id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+// handle deactivates the project when it was exported, the system hooks are sent for every project of the instance
+func (p *projectDestroySystemHookPayload) handle(ev *webhookEvent) error {
+	repos, err := ev.ge.repoProjectManager.ExportedRepos()
+	if err != nil {
+		return err
+	}
+	repo, ok := repos[p.ProjectID]
+	if !ok {
+		sdk.LogDebug(ev.logger, "skipping project destroy, the project wasn't exported", "project_id", p.ProjectID, "project", p.PathWithNamespace)
+		return nil
+	}
+	return ev.ge.repoProjectManager.DeactivateRepoAndProject(repo)
+}
+
+// userDestroySystemHookPayload is the payload of the user_destroy system hook event, the
+// user can't be fetched anymore
+type userDestroySystemHookPayload struct {
+	systemHookPayload
+	UserID   int64  `json:"user_id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (p *userDestroySystemHookPayload) handle(ev *webhookEvent) error {
+	u := api.UserModel{ID: p.UserID, Name: p.Name, Username: p.Username, Email: p.Email}
+	user := u.ToSourceCodeUser(ev.customerID)
+	user.Member = false
+	user.IntegrationInstanceID = &ev.integrationInstanceID
+	return ev.ge.pipe.Write(user)
+}
+
+// groupDestroySystemHookPayload is the payload of the group_destroy system hook event
+type groupDestroySystemHookPayload struct {
+	systemHookPayload
+	GroupID  int64  `json:"group_id"`
+	FullPath string `json:"full_path"`
+}
+
+func (p *groupDestroySystemHookPayload) handle(ev *webhookEvent) error {
+	ge := ev.ge
+	repos, err := ge.repoProjectManager.NamespaceRepos(p.FullPath)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		if err := ge.repoProjectManager.DeactivateRepoAndProject(repo); err != nil {
+			return err
+		}
+	}
+	members, err := namespaceMembers(ge.state, p.GroupID)
+	if err != nil {
+		return err
+	}
+	if err := ge.state.Delete(namespaceMembersKey(p.GroupID)); err != nil {
+		return err
+	}
+	// the members lost the group without a membership event
+	for userRefID := range members {
+		userID, _ := strconv.ParseInt(userRefID, 10, 64)
+		if err := ge.writeUserMembership(userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupRenameSystemHookPayload is the payload of the group_rename system hook event, the
+// projects of the group are renamed with it
+type groupRenameSystemHookPayload struct {
+	systemHookPayload
+	GroupID     int64  `json:"group_id"`
+	FullPath    string `json:"full_path"`
+	OldFullPath string `json:"old_full_path"`
+}
+
+func (p *groupRenameSystemHookPayload) handle(ev *webhookEvent) error {
+	ge := ev.ge
+	repos, err := ge.repoProjectManager.NamespaceRepos(p.OldFullPath)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		renamed, err := api.ProjectByRefID(ge.qc, repo.GitlabID)
+		if err != nil {
+			return err
+		}
+		renamed.IntegrationInstanceID = &ev.integrationInstanceID
+		repo.SourceCodeRepo = *renamed
+		repo.FullPath = renamed.Name
+		if err := ge.repoProjectManager.UpdateRepoAndProject(repo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupMemberSystemHookPayload is the payload of the system hook events changing the members of a group
+type groupMemberSystemHookPayload struct {
+	systemHookPayload
+	GroupID     int64  `json:"group_id"`
+	UserID      int64  `json:"user_id"`
+	GroupAccess string `json:"group_access"`
+}
+
+func (p *groupMemberSystemHookPayload) handle(ev *webhookEvent) error {
+	access := p.GroupAccess
+	if p.EventName == "user_remove_from_group" {
+		access = ""
+	}
+	if err := setNamespaceMember(ev.ge.state, p.GroupID, p.UserID, access); err != nil {
+		return err
+	}
+	return ev.ge.writeUserMembership(p.UserID)
+}
+
+// teamMemberSystemHookPayload is the payload of the system hook events changing the members of a project
+type teamMemberSystemHookPayload struct {
+	systemHookPayload
+	ProjectID int64 `json:"project_id"`
+	UserID    int64 `json:"user_id"`
+}
+
+func (p *teamMemberSystemHookPayload) handle(ev *webhookEvent) error {
+	return ev.ge.writeUserMembership(p.UserID)
+}
+
+// writeUserMembership writes the user with its membership, the user is a member while it
+// belongs to an exported group or project
+func (ge *GitlabExport) writeUserMembership(userID int64) error {
+	user, err := api.UserByID(ge.qc, userID)
+	if err != nil {
+		if api.IsNotFound(err) {
+			sdk.LogDebug(ge.logger, "user not found, skipping membership", "user_id", userID)
+			return nil
+		}
+		return err
+	}
+	memberships, err := api.UserMemberships(ge.qc, userID)
+	if err != nil {
+		return err
+	}
+	namespaces, err := exportedNamespaces(ge.state)
+	if err != nil {
+		return err
+	}
+	repos, err := ge.repoProjectManager.ExportedRepos()
+	if err != nil {
+		return err
+	}
+	user.Member = false
+	for _, membership := range memberships {
+		if user.Member {
+			break
+		}
+		switch membership.SourceType {
+		case "Namespace":
+			user.Member, err = ge.inExportedNamespace(namespaces, membership.SourceID)
+			if err != nil {
+				return err
+			}
+		case "Project":
+			_, user.Member = repos[membership.SourceID]
+		}
+	}
+	user.IntegrationInstanceID = ge.integrationInstanceID
+	return ge.pipe.Write(user)
+}
+
+// inExportedNamespace reports if the group is an exported namespace or one of their subgroups. The
+// exported namespaces are top level, the subgroups are matched by the full path of their parents
+func (ge *GitlabExport) inExportedNamespace(namespaces map[string]string, groupID int64) (bool, error) {
+	if _, ok := namespaces[strconv.FormatInt(groupID, 10)]; ok {
+		return true, nil
+	}
+	fullPath, err := api.GroupFullPath(ge.qc, groupID)
+	if errors.Is(err, api.ErrForbidden) || errors.Is(err, api.ErrNotFound) {
+		sdk.LogDebug(ge.logger, "skipping group membership, the group isn't available", "group_id", groupID, "err", err)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, exported := range namespaces {
+		if strings.HasPrefix(fullPath, exported+"/") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
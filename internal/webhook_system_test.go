@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/gitlab/internal/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookSystemGroupAndProject(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	headers := webhookTokenHeader()
+	instance.Pipe.Reset()
+	server.Respond("GET", "projects/100", 200, `{"id":100,"path_with_namespace":"acme-corp/widgets","web_url":"http://gitlab.acme.test/acme-corp/widgets","default_branch":"main","visibility":"private"}`)
+	assert.NoError(g.WebHook(instance.WebHook("System Hook", []byte(`{"event_name":"group_rename","group_id":10,"full_path":"acme-corp","old_full_path":"acme"}`), headers)))
+	var names []string
+	for _, m := range instance.Pipe.Written() {
+		switch o := m.(type) {
+		case *api.GitlabProjectInternal:
+			names = append(names, o.Name)
+		case *sdk.WorkProject:
+			names = append(names, o.Name)
+		}
+	}
+	assert.Equal([]string{"acme-corp/widgets", "acme-corp/widgets"}, names)
+	instance.Pipe.Reset()
+	assert.NoError(g.WebHook(instance.WebHook("System Hook", []byte(`{"event_name":"project_destroy","project_id":100,"path_with_namespace":"acme-corp/widgets"}`), headers)))
+	var deactivated []string
+	for _, m := range instance.Pipe.Written() {
+		switch o := m.(type) {
+		case *api.GitlabProjectInternal:
+			assert.False(o.Active)
+			deactivated = append(deactivated, o.Name)
+		case *sdk.WorkProject:
+			assert.False(o.Active)
+			deactivated = append(deactivated, o.Name)
+		}
+	}
+	assert.Equal([]string{"acme-corp/widgets", "acme-corp/widgets"}, deactivated)
+	// the projects not exported are left alone
+	instance.Pipe.Reset()
+	assert.NoError(g.WebHook(instance.WebHook("System Hook", []byte(`{"event_name":"project_destroy","project_id":999,"path_with_namespace":"other/gadgets"}`), headers)))
+	assert.Empty(instance.Pipe.Written())
+	var repos stateRepos
+	_, err := instance.State.Get(reposProjectsProcessedKey, &repos)
+	assert.NoError(err)
+	assert.Empty(repos)
+	assertNoMissingFixtures(t, server)
+}
+
+func TestWebHookSystemMembership(t *testing.T) {
+	assert := assert.New(t)
+	g, server, _, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	headers := webhookTokenHeader()
+	instance.Pipe.Reset()
+	// written returns the membership of the users written by the hook
+	written := func() map[string]bool {
+		members := make(map[string]bool)
+		for _, m := range instance.Pipe.Written() {
+			if user, ok := m.(*sdk.SourceCodeUser); ok {
+				members[*user.Username] = user.Member
+			}
+		}
+		instance.Pipe.Reset()
+		return members
+	}
+	// the export keeps the members of the exported groups
+	members, err := namespaceMembers(instance.State, 10)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"1": "Owner", "2": "Developer"}, members)
+	assert.NoError(g.WebHook(instance.WebHook("System Hook", []byte(`{"event_name":"user_add_to_group","group_id":10,"group_access":"Developer","user_id":1}`), headers)))
+	assert.Equal(map[string]bool{"jdoe": true}, written())
+	members, err = namespaceMembers(instance.State, 10)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"1": "Developer", "2": "Developer"}, members)
+	// the groups and projects not exported don't make the user a member
+	server.Respond("GET", "users/1/memberships", 200, `[{"source_id":11,"source_type":"Namespace","access_level":30},{"source_id":999,"source_type":"Project","access_level":30}]`)
+	server.Respond("GET", "groups/11", 200, `{"id":11,"full_path":"acme-labs"}`)
+	assert.NoError(g.WebHook(instance.WebHook("System Hook", []byte(`{"event_name":"user_add_to_group","group_id":11,"group_access":"Developer","user_id":1}`), headers)))
+	assert.Equal(map[string]bool{"jdoe": false}, written())
+	assert.False(instance.State.Exists(namespaceMembersKey(11)))
+	// the subgroups of the exported groups make the user a member
+	server.Respond("GET", "users/1/memberships", 200, `[{"source_id":12,"source_type":"Namespace","access_level":30}]`)
+	server.Respond("GET", "groups/12", 200, `{"id":12,"full_path":"acme/tools"}`)
+	assert.NoError(g.WebHook(instance.WebHook("System Hook", []byte(`{"event_name":"user_add_to_group","group_id":12,"group_access":"Developer","user_id":1}`), headers)))
+	assert.Equal(map[string]bool{"jdoe": true}, written())
+	assert.False(instance.State.Exists(namespaceMembersKey(12)))
+	// the members lose their only membership with the group
+	server.Respond("GET", "users/1/memberships", 200, `[]`)
+	server.Respond("GET", "users/2", 200, `{"id":2,"name":"John Smith","username":"jsmith","email":"john@acme.test"}`)
+	server.Respond("GET", "users/2/memberships", 200, `[]`)
+	assert.NoError(g.WebHook(instance.WebHook("System Hook", []byte(`{"event_name":"group_destroy","group_id":10,"full_path":"acme"}`), headers)))
+	assert.Equal(map[string]bool{"jdoe": false, "jsmith": false}, written())
+	assert.False(instance.State.Exists(namespaceMembersKey(10)))
+	assert.NoError(g.WebHook(instance.WebHook("System Hook", []byte(`{"event_name":"user_destroy","user_id":2,"name":"John Smith","username":"jsmith","email":"john@acme.test"}`), headers)))
+	assert.Equal(map[string]bool{"jsmith": false}, written())
+	assertNoMissingFixtures(t, server)
+}
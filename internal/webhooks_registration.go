@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
@@ -23,6 +24,7 @@ func (i *GitlabIntegration) registerWebhooks(ge GitlabExport, namespaces []*api.
 		integrationInstanceID: *integrationInstanceID,
 		manager:               webhookManager,
 		ge:                    &ge,
+		secret:                webhookManager.Secret(),
 	}
 
 	loginUser, err := api.LoginUser(ge.qc)
@@ -34,7 +36,6 @@ func (i *GitlabIntegration) registerWebhooks(ge GitlabExport, namespaces []*api.
 		err = wr.registerWebhook(sdk.WebHookScopeSystem, "", "")
 		if err != nil {
 			sdk.LogDebug(ge.logger, "error registering sytem webhooks", "err", err)
-			webhookManager.Errored(customerID, *ge.integrationInstanceID, gitlabRefType, "system", sdk.WebHookScopeSystem, err)
 			return err
 		}
 	}
@@ -57,7 +58,7 @@ func (i *GitlabIntegration) registerWebhooks(ge GitlabExport, namespaces []*api.
 				}
 			} else {
 				user, err := api.GroupUser(ge.qc, namespace, loginUser.StrID)
-				if err != nil && strings.Contains(err.Error(), "Not found") {
+				if api.IsNotFound(err) {
 					namespace.MarkedToCreateProjectWebHooks = true
 					sdk.LogWarn(ge.logger, "use is not member of this namespace, will try to create project webhooks", "namespace", namespace.Name, "user_id", loginUser.RefID, "user_name", loginUser.Name, "err", err)
 					continue
@@ -105,7 +106,6 @@ func (i *GitlabIntegration) registerWebhooks(ge GitlabExport, namespaces []*api.
 					err = wr.registerWebhook(sdk.WebHookScopeRepo, project.RefID, project.Name)
 					if err != nil {
 						err := fmt.Errorf("error trying to register project webhooks err => %s", err)
-						webhookManager.Errored(customerID, *integrationInstanceID, gitlabRefType, project.ID, sdk.WebHookScopeProject, err)
 						sdk.LogError(ge.logger, "error creating project webhook", "err", err)
 						return err
 					}
@@ -123,7 +123,6 @@ func (i *GitlabIntegration) registerWebhooks(ge GitlabExport, namespaces []*api.
 					err = wr.registerWebhook(sdk.WebHookScopeRepo, project.RefID, project.Name)
 					if err != nil {
 						err := fmt.Errorf("error trying to register project webhooks err => %s", err)
-						webhookManager.Errored(customerID, *integrationInstanceID, gitlabRefType, project.ID, sdk.WebHookScopeProject, err)
 						sdk.LogError(ge.logger, "error creating project webhook", "err", err)
 						return err
 					}
@@ -144,63 +143,194 @@ type webHookRegistration struct {
 	customerID            string
 	integrationInstanceID string
 	ge                    *GitlabExport
+	// secret is the token gitlab sends in the deliveries of the hooks
+	secret string
 }
 
+// statuses of the hooks after the reconciliation
+const (
+	webhookHealthy   = "healthy"
+	webhookCreated   = "created"
+	webhookPatched   = "patched"
+	webhookRotated   = "rotated"
+	webhookRecreated = "recreated"
+)
+
 func (wr *webHookRegistration) registerWebhook(whType sdk.WebHookScope, entityID, entityName string) error {
 
 	sdk.LogDebug(wr.ge.logger, "registering webhook", "type", whType, "entityID", entityID, "entityName", entityName)
 
+	status, hook, err := wr.reconcileWebhook(whType, entityID, entityName)
+	if err != nil {
+		if serr := wr.setWebhookErrored(whType, entityID, err); serr != nil {
+			sdk.LogError(wr.ge.logger, "error keeping the errored webhook", "err", serr)
+		}
+		return err
+	}
+
+	sdk.LogInfo(wr.ge.logger, "webhook reconciled", "scope", whType, "entity_id", entityID, "entity_name", entityName, "status", status)
+
+	return wr.reportWebhook(whType, entityID, entityName, status, hook)
+}
+
+// the hooks reported as errored in pinpoint, only these are registered again once healthy
+const webhookErroredKeyPrefix = "webhook_errored_"
+
+const webhookErroredExpiry = 30 * 24 * time.Hour
+
+func webhookErroredKey(whType sdk.WebHookScope, entityID string) string {
+	return webhookErroredKeyPrefix + string(whType) + "_" + entityID
+}
+
+// setWebhookErrored reports the hook as errored in pinpoint
+func (wr *webHookRegistration) setWebhookErrored(whType sdk.WebHookScope, entityID string, err error) error {
+	wr.manager.Errored(wr.customerID, wr.integrationInstanceID, gitlabRefType, entityID, whType, err)
+	return wr.ge.state.SetWithExpires(webhookErroredKey(whType, entityID), err.Error(), webhookErroredExpiry)
+}
+
+// reportWebhook sets the status of the reconciled hook in pinpoint. The patched hooks are reported as
+// errored until the next reconciliation finds them healthy. Creating the pinpoint hook is the only way
+// to clear the error, it's only done for the hooks reported as errored, and the gitlab hook is updated
+// when the pinpoint hook gets a new url. The created hooks are already healthy
+func (wr *webHookRegistration) reportWebhook(whType sdk.WebHookScope, entityID, entityName string, status string, hook *api.GitlabWebhook) error {
+	key := webhookErroredKey(whType, entityID)
+	switch status {
+	case webhookPatched:
+		return wr.setWebhookErrored(whType, entityID, errWebhookDrifted)
+	case webhookHealthy, webhookRotated:
+		if !wr.ge.state.Exists(key) {
+			return nil
+		}
+		url, err := wr.manager.Create(wr.customerID, wr.integrationInstanceID, gitlabRefType, entityID, whType, webhookURLParams(whType, entityID)...)
+		if err != nil {
+			return err
+		}
+		if url != hook.URL {
+			sdk.LogInfo(wr.ge.logger, "updating webhook url", "scope", whType, "entity_id", entityID, "entity_name", entityName)
+			if err := wr.updateWebhookURL(whType, entityID, entityName, hook, url); err != nil {
+				return err
+			}
+		}
+	}
+	return wr.ge.state.Delete(key)
+}
+
+// updateWebhookURL sets the url of the gitlab hook, the system hooks can't be edited and are created again
+func (wr *webHookRegistration) updateWebhookURL(whType sdk.WebHookScope, entityID, entityName string, hook *api.GitlabWebhook, url string) error {
+	if whType != sdk.WebHookScopeSystem {
+		return api.UpdateWebHook(whType, wr.ge.qc, url, entityID, entityName, strconv.FormatInt(hook.ID, 10), wr.secret)
+	}
+	if err := api.DeleteWebHook(whType, wr.ge.qc, entityID, entityName, strconv.FormatInt(hook.ID, 10)); err != nil {
+		return err
+	}
+	return api.CreateWebHook(whType, wr.ge.qc, url, entityID, entityName, wr.secret)
+}
+
+// errWebhookDrifted is reported for the hooks which lost events or settings in gitlab
+var errWebhookDrifted = errors.New("webhook events or settings changed in gitlab, the hook was patched")
+
+// webhookURLParams are the params of the pinpoint hook url
+func webhookURLParams(whType sdk.WebHookScope, entityID string) []string {
+	params := []string{"version=" + hookVersion}
+	if whType == sdk.WebHookScopeRepo {
+		params = append(params, "ref_id="+entityID)
+	}
+	return params
+}
+
+// reconcileWebhook makes the hooks of the entity in gitlab match the one registered in pinpoint.
+// Gitlab deletes, disables and edits hooks without pinpoint knowing, so they are checked on every
+// export. The hooks of older versions, duplicates and disabled hooks are deleted, the hook is patched
+// when its events drifted or the secret changed, and created again when it's missing. Creating the
+// pinpoint hook again clears its errored state
+func (wr *webHookRegistration) reconcileWebhook(whType sdk.WebHookScope, entityID, entityName string) (string, *api.GitlabWebhook, error) {
+
 	pinptWhURL, err := wr.ge.isWebHookInstalledForCurrentVersion(whType, wr.manager, wr.customerID, wr.integrationInstanceID, entityID)
 	if err != nil {
-		sdk.LogDebug(wr.ge.logger, "webhook already installed", "webhook_id", entityID, "type", whType)
-		return nil
+		return "", nil, err
 	}
 
 	webHooks, err := wr.ge.getHooks(whType, entityID, entityName)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
 	sdk.LogDebug(wr.ge.logger, "source webhooks length", "len", len(webHooks), "webhooks", webHooks)
 
-	var found bool
+	var kept *api.GitlabWebhook
+	var deleted bool
 	for _, wh := range webHooks {
-		if strings.Contains(wh.URL, "event.api") && strings.Contains(wh.URL, "pinpoint.com") && strings.Contains(wh.URL, wr.integrationInstanceID) {
-			// Check the version comming from event-api is the same
-			if wh.URL == pinptWhURL {
-				found = true
-			} else {
-				err := api.DeleteWebHook(whType, wr.ge.qc, entityID, entityName, strconv.FormatInt(wh.ID, 10))
-				if err != nil {
-					return err
-				}
-			}
+		if !wr.isPinpointWebhook(wh) {
+			continue
+		}
+		if kept == nil && wh.URL == pinptWhURL && !wh.Disabled() {
+			kept = wh
+			continue
+		}
+		sdk.LogInfo(wr.ge.logger, "deleting webhook", "url", wh.URL, "disabled", wh.Disabled(), "duplicate", wh.URL == pinptWhURL)
+		if err := api.DeleteWebHook(whType, wr.ge.qc, entityID, entityName, strconv.FormatInt(wh.ID, 10)); err != nil {
+			return "", nil, err
 		}
+		deleted = true
 	}
 
-	sdk.LogDebug(wr.ge.logger, "pinpoint webhook", "found", found)
+	sdk.LogDebug(wr.ge.logger, "pinpoint webhook", "found", kept != nil)
 
-	if !found {
-		if pinptWhURL != "" {
-			wr.manager.Delete(wr.customerID, wr.integrationInstanceID, gitlabRefType, entityID, whType)
+	if kept != nil {
+		tokenChanged, err := wr.webhookTokenChanged(whType, entityID)
+		if err != nil {
+			return "", nil, err
 		}
-		params := []string{"version=" + hookVersion}
-		if whType == sdk.WebHookScopeRepo {
-			params = append(params, "ref_id="+entityID)
+		drifted := kept.Drifted(whType)
+		if !drifted && !tokenChanged {
+			// refreshed so the hash doesn't expire while the hook is healthy
+			return webhookHealthy, kept, wr.setWebhookToken(whType, entityID)
 		}
-		url, err := wr.manager.Create(wr.customerID, wr.integrationInstanceID, gitlabRefType, entityID, whType, params...)
-		if err != nil {
-			wr.manager.Delete(wr.customerID, wr.integrationInstanceID, gitlabRefType, entityID, whType)
-			return err
+		if whType != sdk.WebHookScopeSystem {
+			err := api.UpdateWebHook(whType, wr.ge.qc, pinptWhURL, entityID, entityName, strconv.FormatInt(kept.ID, 10), wr.secret)
+			if err != nil {
+				return "", nil, err
+			}
+			if err := wr.setWebhookToken(whType, entityID); err != nil {
+				return "", nil, err
+			}
+			if drifted {
+				return webhookPatched, kept, nil
+			}
+			return webhookRotated, kept, nil
 		}
-		err = api.CreateWebHook(whType, wr.ge.qc, url, entityID, entityName)
-		if err != nil {
-			return err
+		if err := api.DeleteWebHook(whType, wr.ge.qc, entityID, entityName, strconv.FormatInt(kept.ID, 10)); err != nil {
+			return "", nil, err
 		}
-		sdk.LogDebug(wr.ge.logger, "webhook created", "scope", whType, "entity_id", entityID, "entity_name", entityName)
+		deleted = true
 	}
 
-	return nil
+	if pinptWhURL != "" {
+		wr.manager.Delete(wr.customerID, wr.integrationInstanceID, gitlabRefType, entityID, whType)
+	}
+	url, err := wr.manager.Create(wr.customerID, wr.integrationInstanceID, gitlabRefType, entityID, whType, webhookURLParams(whType, entityID)...)
+	if err != nil {
+		wr.manager.Delete(wr.customerID, wr.integrationInstanceID, gitlabRefType, entityID, whType)
+		return "", nil, err
+	}
+	err = api.CreateWebHook(whType, wr.ge.qc, url, entityID, entityName, wr.secret)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := wr.setWebhookToken(whType, entityID); err != nil {
+		return "", nil, err
+	}
+	sdk.LogDebug(wr.ge.logger, "webhook created", "scope", whType, "entity_id", entityID, "entity_name", entityName)
+
+	if deleted {
+		return webhookRecreated, nil, nil
+	}
+	return webhookCreated, nil, nil
+}
+
+// isPinpointWebhook returns true if the hook was created for this integration instance
+func (wr *webHookRegistration) isPinpointWebhook(wh *api.GitlabWebhook) bool {
+	return strings.Contains(wh.URL, "event.api") && strings.Contains(wh.URL, "pinpoint.com") && strings.Contains(wh.URL, wr.integrationInstanceID)
 }
 
 func (wr *webHookRegistration) unregisterWebhook(whType sdk.WebHookScope, entityID, entityName string) error {
@@ -249,7 +379,7 @@ func (ge *GitlabExport) isWebHookInstalledForCurrentVersion(webhookType sdk.WebH
 }
 
 func (ge *GitlabExport) getHooks(webhookType sdk.WebHookScope, entityID, entityName string) (gwhs []*api.GitlabWebhook, rerr error) {
-	rerr = api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (np api.NextPage, rerr error) {
+	rerr = api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (np api.NextPage, rerr error) {
 		pi, whs, err := api.GetWebHooksPage(webhookType, ge.qc, entityID, entityName, params)
 		if err != nil {
 			return pi, err
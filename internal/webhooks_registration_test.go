@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookReconcile(t *testing.T) {
+	assert := assert.New(t)
+	g, server, manager, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	hooks := manager.WebHooks.Hooks()
+	assert.Len(hooks, 1)
+	hookURL := hooks[0]
+	hook := func(id int, events bool, status string) string {
+		return fmt.Sprintf(`{"id":%d,"url":%q,"enable_ssl_verification":true,"alert_status":%q,"merge_requests_events":%t,"note_events":true,"issues_events":true,"push_events":true,"pipeline_events":true,"job_events":true,"deployment_events":true}`, id, hookURL, status, events)
+	}
+	// reconcile exports again with the hooks gitlab has and returns the hook requests it made
+	reconcile := func(hooks ...string) []string {
+		server.Respond("GET", "groups/10/hooks", 200, "["+strings.Join(hooks, ",")+"]")
+		before := len(server.Requests())
+		assert.NoError(g.Export(instance.Export(false)))
+		var requests []string
+		for _, r := range server.Requests()[before:] {
+			if r.Method != "GET" && strings.HasPrefix(r.Path, "groups/10/hooks") {
+				requests = append(requests, r.Method+" "+r.Path)
+			}
+		}
+		return requests
+	}
+	assert.Empty(reconcile(hook(1, true, "executable")))
+	assert.Equal([]string{"DELETE groups/10/hooks/2"}, reconcile(hook(1, true, "executable"), hook(2, true, "executable")))
+	assert.Equal([]string{"PUT groups/10/hooks/1"}, reconcile(hook(1, false, "executable")))
+	// the patched hook is errored until it's found healthy
+	errored := func() bool {
+		for key, err := range manager.WebHooks.Errors() {
+			if strings.HasSuffix(key, "/10") {
+				assert.Equal(errWebhookDrifted, err)
+				return true
+			}
+		}
+		return false
+	}
+	assert.True(errored())
+	// the events older instances don't send aren't drift
+	assert.Empty(reconcile(fmt.Sprintf(`{"id":1,"url":%q,"enable_ssl_verification":true,"merge_requests_events":true}`, hookURL)))
+	assert.False(errored())
+	assert.Equal([]string{"DELETE groups/10/hooks/1", "POST groups/10/hooks"}, reconcile(hook(1, true, "disabled")))
+	assert.Equal([]string{"POST groups/10/hooks"}, reconcile())
+	assert.Len(manager.WebHooks.Hooks(), 1)
+}
+
+func TestWebhookReconcileRenewedURL(t *testing.T) {
+	assert := assert.New(t)
+	g, server, manager, instance := newTestIntegration(t)
+	assert.NoError(g.Export(instance.Export(true)))
+	hookURL := manager.WebHooks.Hooks()[0]
+	hook := func(url string, events bool) string {
+		return fmt.Sprintf(`{"id":1,"url":%q,"enable_ssl_verification":true,"alert_status":"executable","merge_requests_events":%t,"note_events":true,"issues_events":true,"push_events":true,"pipeline_events":true,"job_events":true,"deployment_events":true}`, url, events)
+	}
+	// reconcile exports again with the hook gitlab has and returns the urls of the hooks updated
+	reconcile := func(hook string) []string {
+		server.Respond("GET", "groups/10/hooks", 200, "["+hook+"]")
+		before := len(server.Requests())
+		assert.NoError(g.Export(instance.Export(false)))
+		var urls []string
+		for _, r := range server.Requests()[before:] {
+			if r.Method == "PUT" && r.Path == "groups/10/hooks/1" {
+				var body map[string]interface{}
+				assert.NoError(json.Unmarshal([]byte(r.Body), &body))
+				urls = append(urls, body["url"].(string))
+			}
+		}
+		return urls
+	}
+	// the healthy hooks aren't registered again
+	manager.WebHooks.SetRenewURLs(true)
+	assert.Empty(reconcile(hook(hookURL, true)))
+	assert.Equal([]string{hookURL}, manager.WebHooks.Hooks())
+	assert.Equal([]string{hookURL}, reconcile(hook(hookURL, false)))
+	assert.Len(manager.WebHooks.Errors(), 1)
+	// clearing the error gives the hook a new url, gitlab is updated with it
+	renewed := reconcile(hook(hookURL, true))
+	assert.Len(renewed, 1)
+	assert.NotEqual(hookURL, renewed[0])
+	assert.Equal(renewed, manager.WebHooks.Hooks())
+	assert.Empty(manager.WebHooks.Errors())
+	assert.Empty(reconcile(hook(renewed[0], true)))
+	assert.Equal(renewed, manager.WebHooks.Hooks())
+}
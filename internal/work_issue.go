@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/gitlab/internal/api"
@@ -58,26 +59,25 @@ func (ge *GitlabExport) exportProjectIssues(project *api.GitlabProjectInternal,
 	<-done
 }
 
-func (ge *GitlabExport) fetchProjectIssues(project *api.GitlabProjectInternal, pissues chan *sdk.WorkIssue) (err error) {
-	var nP api.NextPage
-	for {
-		nP, err = api.WorkIssuesPage(ge.qc, project, nP, pissues)
-		if err != nil {
-			return err
-		}
-		if nP == "" {
-			return
-		}
+func (ge *GitlabExport) fetchProjectIssues(project *api.GitlabProjectInternal, pissues chan *sdk.WorkIssue) error {
+	// the unchanged issues are known by the work manager from the state, without it
+	// the board and sprint columns need every issue to be fetched again
+	var updatedAfter time.Time
+	if ge.workManagerRestored {
+		updatedAfter = ge.lastExportDate
 	}
+	return api.PaginateCursor(ge.logger, updatedAfter, func(log sdk.Logger, after api.NextPage, stop *api.PageStop) (api.PageInfo, error) {
+		return api.WorkIssuesPage(ge.qc, project, updatedAfter, after, stop, pissues)
+	})
 }
 
-func (ge *GitlabExport) writeSingleIssue(project *sdk.SourceCodeRepo, iid int64) error {
+func (ge *GitlabExport) writeSingleIssue(project *api.GitlabProjectInternal, iid int64) error {
 
 	params := url.Values{}
 	params.Set("iids[]", strconv.FormatInt(iid, 10))
 
 	issuesC := make(chan *sdk.WorkIssue, 1)
-	_, err := api.WorkSingleIssue(ge.qc, project, ge.lastExportDate, params, issuesC)
+	_, err := api.WorkSingleIssue(ge.qc, project, nil, params, issuesC)
 	if err != nil {
 		return err
 	}
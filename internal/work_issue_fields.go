@@ -93,7 +93,7 @@ func (ge *GitlabExport) exportIssueFields(project *api.GitlabProjectInternal, is
 
 func (ge *GitlabExport) fetchIssueDiscussions(project *api.GitlabProjectInternal, issue *sdk.WorkIssue, projectUsers api.UsernameMap) (changelogs []sdk.WorkIssueChangeLog, rerr error) {
 
-	rerr = api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (np api.NextPage, rerr error) {
+	rerr = api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (np api.NextPage, rerr error) {
 		np, arr, comments, err := api.WorkIssuesDiscussionPage(ge.qc, project, issue, projectUsers, params)
 		if err != nil {
 			return np, err
@@ -104,7 +104,7 @@ func (ge *GitlabExport) fetchIssueDiscussions(project *api.GitlabProjectInternal
 		for _, c := range comments {
 			c.IntegrationInstanceID = ge.integrationInstanceID
 			if err := ge.pipe.Write(c); err != nil {
-				return
+				return np, err
 			}
 		}
 		return
@@ -126,7 +126,7 @@ func (ge *GitlabExport) writeProjectIssues(commits []*sdk.SourceCodePullRequestC
 
 func (ge *GitlabExport) fetchEpicIssueDiscussions(namespace *api.Namespace, projects []*api.GitlabProjectInternal, epic *sdk.WorkIssue, projectUsers api.UsernameMap) (changelogs []sdk.WorkIssueChangeLog, err error) {
 
-	err = api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (api.NextPage, error) {
+	err = api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, _ *api.PageStop) (api.NextPage, error) {
 		pi, arr, comments, err := api.WorkEpicIssuesDiscussionPage(ge.qc, namespace, projects, epic, projectUsers, params)
 		if err != nil {
 			return pi, err
@@ -2,15 +2,14 @@ package internal
 
 import (
 	"net/url"
-	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/gitlab/internal/api"
 )
 
 func (ge *GitlabExport) exportRepoMilestones(project *api.GitlabProjectInternal) error {
-	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (pi api.NextPage, rerr error) {
-		pi, err := api.RepoMilestonesPage(ge.qc, project, ge.lastExportDate, params)
+	return api.Paginate(ge.logger, "", ge.lastExportDate, func(log sdk.Logger, params url.Values, stop *api.PageStop) (pi api.NextPage, rerr error) {
+		pi, err := api.RepoMilestonesPage(ge.qc, project, stop, params)
 		if err != nil {
 			return pi, err
 		}
@@ -32,8 +31,8 @@ func (ge *GitlabExport) exportProjectsMilestones(repos []*api.GitlabProjectInter
 
 func (ge *GitlabExport) exportProjectMilestones(project *api.GitlabProjectInternal) error {
 
-	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (pi api.NextPage, rerr error) {
-		pi, err := api.RepoMilestonesPage(ge.qc, project, ge.lastExportDate, params)
+	return api.Paginate(ge.logger, "", ge.lastExportDate, func(log sdk.Logger, params url.Values, stop *api.PageStop) (pi api.NextPage, rerr error) {
+		pi, err := api.RepoMilestonesPage(ge.qc, project, stop, params)
 		if err != nil {
 			return pi, err
 		}
@@ -47,8 +46,8 @@ func (ge *GitlabExport) exportGroupMilestones(namespace *api.Namespace, repos []
 		return nil
 	}
 
-	return api.Paginate(ge.logger, "", time.Time{}, func(log sdk.Logger, params url.Values, t time.Time) (pi api.NextPage, rerr error) {
-		pi, err := api.GroupMilestonesPage(ge.qc, namespace, repos, ge.lastExportDate, params)
+	return api.Paginate(ge.logger, "", ge.lastExportDate, func(log sdk.Logger, params url.Values, stop *api.PageStop) (pi api.NextPage, rerr error) {
+		pi, err := api.GroupMilestonesPage(ge.qc, namespace, repos, stop, params)
 		if err != nil {
 			return pi, err
 		}